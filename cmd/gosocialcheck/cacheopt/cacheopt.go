@@ -1,15 +1,23 @@
-// Package cacheopt resolves the persistent --cache-mode flag into a
-// [cache.Opt] slice that can be passed to [cache.New].
+// Package cacheopt resolves the persistent --cache-mode, --trust-categories,
+// and --config flags into a [cache.Opt] slice that can be passed to [cache.New].
 package cacheopt
 
 import (
+	"os"
+	"slices"
+
 	"github.com/spf13/cobra"
 
 	"github.com/AkihiroSuda/gosocialcheck/pkg/cache"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source/custom"
 )
 
-// FromCommand reads the persistent --cache-mode flag from cmd and returns
-// the matching [cache.Opt]s.
+// FromCommand reads the persistent --cache-mode, --trust-categories, and
+// --config flags from cmd and returns the matching [cache.Opt]s.
+// The categories of the custom sources in the config file are trusted unless
+// --trust-categories (or $GOSOCIALCHECK_TRUST_CATEGORIES) is explicitly set,
+// and the forges in the config file are registered.
 func FromCommand(cmd *cobra.Command) ([]cache.Opt, error) {
 	flags := cmd.Flags()
 	cacheMode, _ := flags.GetString("cache-mode")
@@ -17,5 +25,40 @@ func FromCommand(cmd *cobra.Command) ([]cache.Opt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return []cache.Opt{cache.WithMode(mode)}, nil
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RegisterForges()
+	opts := []cache.Opt{cache.WithMode(mode), cache.WithSources(cfg), cache.WithSourceRefs(cfg.SourceRefs)}
+	cats := slices.Clone(categories.Default)
+	if trustCategories, _ := flags.GetString("trust-categories"); trustCategories != "" {
+		cats, err = categories.Parse(trustCategories, cfg.Categories()...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, envSet := os.LookupEnv("GOSOCIALCHECK_TRUST_CATEGORIES")
+	if !flags.Changed("trust-categories") && !envSet {
+		for _, cat := range cfg.Categories() {
+			if !slices.Contains(cats, cat) {
+				cats = append(cats, cat)
+			}
+		}
+	}
+	opts = append(opts, cache.WithCategories(cats...))
+	return opts, nil
+}
+
+// loadConfig loads the file specified by --config, or the default config file
+// if it exists.
+func loadConfig(cmd *cobra.Command) (*custom.Config, error) {
+	if configFile, _ := cmd.Flags().GetString("config"); configFile != "" {
+		return custom.Load(configFile, false)
+	}
+	// Without a user config directory (e.g. $HOME unset), there is no default config.
+	if configFile, err := custom.DefaultConfigPath(); err == nil {
+		return custom.Load(configFile, true)
+	}
+	return &custom.Config{}, nil
 }
@@ -3,6 +3,7 @@ package info
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -44,6 +45,7 @@ func action(cmd *cobra.Command, args []string) error {
 		return enc.Encode(s)
 	}
 	fmt.Fprintf(w, "Cache mode:     %s\n", s.Mode)
+	fmt.Fprintf(w, "Categories:     %s\n", strings.Join(s.Categories, ", "))
 	fmt.Fprintln(w, "Local:")
 	fmt.Fprintf(w, "  Path:         %s\n", s.Local.Dir)
 	fmt.Fprintf(w, "  Exists:       %t\n", s.Local.Exists)
@@ -29,6 +29,16 @@ func New() *cobra.Command {
 	flags := cmd.Flags()
 	flags.Bool("gha", false,
 		"Emit diagnostics as GitHub Actions workflow commands and always exit 0")
+	flags.String("format", string(analyzer.FormatText),
+		`Output format ("text", "json", "jsonl", or "sarif"); structured formats are written to stdout`)
+	flags.String("match", string(analyzer.MatchExact),
+		`How closely a trusted project's version of a module has to match ("exact", "minor", "major", or "path"); "major" is "minor" for v0`)
+	flags.Bool("explain", false,
+		"Explain why each module is (or is not) considered adopted")
+	flags.String("baseline", "",
+		"Baseline file of known findings to suppress (written by --write-baseline)")
+	flags.String("write-baseline", "",
+		"Record the current findings to the baseline file instead of reporting them")
 	return cmd
 }
 
@@ -37,6 +47,49 @@ func action(cmd *cobra.Command, args []string) error {
 	if len(args) == 0 {
 		return errors.New("at least one package pattern is required (e.g. ./...)")
 	}
+	flags := cmd.Flags()
+	gha, err := flags.GetBool("gha")
+	if err != nil {
+		return err
+	}
+	formatStr, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+	format, err := analyzer.ParseFormat(formatStr)
+	if err != nil {
+		return err
+	}
+	if gha && format != analyzer.FormatText {
+		return fmt.Errorf("--gha cannot be combined with --format=%s", format)
+	}
+	matchStr, err := flags.GetString("match")
+	if err != nil {
+		return err
+	}
+	match, err := analyzer.ParseMatch(matchStr)
+	if err != nil {
+		return err
+	}
+	explain, err := flags.GetBool("explain")
+	if err != nil {
+		return err
+	}
+	baselineFile, err := flags.GetString("baseline")
+	if err != nil {
+		return err
+	}
+	var baseline *analyzer.Baseline
+	if baselineFile != "" {
+		baseline, err = analyzer.ReadBaseline(baselineFile)
+		if err != nil {
+			return err
+		}
+	}
+	writeBaseline, err := flags.GetString("write-baseline")
+	if err != nil {
+		return err
+	}
 	cacheOpts, err := cacheopt.FromCommand(cmd)
 	if err != nil {
 		return err
@@ -52,17 +105,17 @@ func action(cmd *cobra.Command, args []string) error {
 	if err = c.EnsureUpdated(ctx); err != nil {
 		return err
 	}
-	flags := cmd.Flags()
-	gha, err := flags.GetBool("gha")
-	if err != nil {
-		return err
-	}
-	goflags := flagutil.PFlagSetToGoFlagSet(flags, []string{"debug", "cache-mode", "gha"})
+	goflags := flagutil.PFlagSetToGoFlagSet(flags, []string{"debug", "cache-mode", "trust-categories", "config", "gha", "format", "match", "explain", "baseline", "write-baseline"})
 	opts := analyzer.Opts{
-		Flags:      *goflags,
-		Cache:      c,
-		GHA:        gha,
-		OnProgress: onProgress,
+		Flags:         *goflags,
+		Cache:         c,
+		GHA:           gha,
+		Format:        format,
+		Match:         match,
+		Explain:       explain,
+		Baseline:      baseline,
+		WriteBaseline: writeBaseline,
+		OnProgress:    onProgress,
 	}
 	a, err := analyzer.New(ctx, opts)
 	if err != nil {
@@ -7,7 +7,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/cacheopt"
+	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/envutil"
 	"github.com/AkihiroSuda/gosocialcheck/pkg/cache"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
 	"github.com/AkihiroSuda/gosocialcheck/pkg/progress"
 )
 
@@ -22,6 +24,16 @@ func New() *cobra.Command {
 	flags := cmd.Flags()
 	flags.String("cache-remote", cache.DefaultRemoteURL,
 		"URL of the remote cache repository")
+	flags.Bool("default-branch", false,
+		"Also index the tip of the default branch of every repository (--cache-mode=local only)")
+	flags.Bool("discover-modules", false,
+		"Also index the nested modules (go.mod) found in every repository (--cache-mode=local only)")
+	flags.Bool("keep-going", false,
+		"Keep going after the failures of repositories, and update the cache with the rest (--cache-mode=local only)")
+	flags.String("fetcher", envutil.String("GOSOCIALCHECK_FETCHER", string(cache.FetcherForge)),
+		`How to fetch the repositories ("forge" for the forge APIs, or "goproxy" for $GOPROXY) (--cache-mode=local only) [$GOSOCIALCHECK_FETCHER]`)
+	flags.Duration("max-retry-wait", netutil.DefaultMaxRetryWait,
+		"Maximum wait before retrying a request, e.g. 1h to wait for the reset of the GitHub API rate limit")
 	return cmd
 }
 
@@ -35,8 +47,22 @@ func action(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	cacheRemote, _ := cmd.Flags().GetString("cache-remote")
+	defaultBranch, _ := cmd.Flags().GetBool("default-branch")
+	discoverModules, _ := cmd.Flags().GetBool("discover-modules")
+	keepGoing, _ := cmd.Flags().GetBool("keep-going")
+	fetcherStr, _ := cmd.Flags().GetString("fetcher")
+	maxRetryWait, _ := cmd.Flags().GetDuration("max-retry-wait")
+	fetcher, err := cache.ParseFetcher(fetcherStr)
+	if err != nil {
+		return err
+	}
 	cacheOpts = append(cacheOpts,
 		cache.WithRemoteURL(cacheRemote),
+		cache.WithDefaultBranch(defaultBranch),
+		cache.WithDiscoverModules(discoverModules),
+		cache.WithKeepGoing(keepGoing),
+		cache.WithFetcher(fetcher),
+		cache.WithMaxRetryWait(maxRetryWait),
 		cache.WithProgressEventHandler(onProgress),
 	)
 	c, err := cache.New(cacheOpts...)
@@ -0,0 +1,71 @@
+package why
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/cacheopt"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/cache"
+)
+
+const example = `
+  # Show the trusted projects that use golang.org/x/sys@v0.30.0,
+  # or the other versions they use
+  gosocialcheck why golang.org/x/sys@v0.30.0
+
+  # Show every version of golang.org/x/sys used by trusted projects
+  gosocialcheck why golang.org/x/sys
+`
+
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "why MODULE[@VERSION]",
+		Short:                 "Explain why a module is (or is not) considered adopted",
+		Example:               example,
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  action,
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("json", false, "JSON output")
+	return cmd
+}
+
+func action(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+	jsonOut, err := flags.GetBool("json")
+	if err != nil {
+		return err
+	}
+	modPath, version, _ := strings.Cut(args[0], "@")
+	if modPath == "" {
+		return fmt.Errorf("invalid module %q (expected MODULE[@VERSION])", args[0])
+	}
+	cacheOpts, err := cacheopt.FromCommand(cmd)
+	if err != nil {
+		return err
+	}
+	c, err := cache.New(cacheOpts...)
+	if err != nil {
+		return err
+	}
+	if _, err = c.LastUpdated(); err != nil {
+		return err
+	}
+	e, err := c.Explain(ctx, modPath, version, "")
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	if jsonOut {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e)
+	}
+	return e.WriteText(w)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/lmittmann/tint"
@@ -12,9 +13,13 @@ import (
 	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/commands/lookup"
 	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/commands/run"
 	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/commands/update"
+	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/commands/why"
 	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/envutil"
 	"github.com/AkihiroSuda/gosocialcheck/cmd/gosocialcheck/version"
 	"github.com/AkihiroSuda/gosocialcheck/pkg/cache"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	_ "github.com/AkihiroSuda/gosocialcheck/pkg/source/builtin"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source/custom"
 )
 
 var logLevel = new(slog.LevelVar)
@@ -57,6 +62,13 @@ func newRootCommand() *cobra.Command {
 	flags.String("cache-mode",
 		envutil.String("GOSOCIALCHECK_CACHE_MODE", string(cache.ModeAuto)),
 		`cache mode ("auto", "remote", or "local") [$GOSOCIALCHECK_CACHE_MODE]`)
+	flags.String("trust-categories",
+		envutil.String("GOSOCIALCHECK_TRUST_CATEGORIES", strings.Join(categories.Default, ",")),
+		"comma-separated categories of trusted projects ("+strings.Join(categories.All, ", ")+
+			"); the categories other than the default, e.g. "+categories.ASFTLP+" and "+categories.GoToolchain+", are opt-in [$GOSOCIALCHECK_TRUST_CATEGORIES]")
+	flags.String("config", envutil.String("GOSOCIALCHECK_CONFIG", ""),
+		"config file declaring custom trust sources (default: $XDG_CONFIG_HOME/gosocialcheck/"+custom.ConfigFilename+
+			" if exists) [$GOSOCIALCHECK_CONFIG]")
 	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		flags := cmd.Flags()
 		if debug, _ := flags.GetBool("debug"); debug {
@@ -74,6 +86,7 @@ func newRootCommand() *cobra.Command {
 		lookup.New(),
 		run.New(),
 		info.New(),
+		why.New(),
 	)
 	return cmd
 }
@@ -14,6 +14,7 @@ require (
 )
 
 require (
+	github.com/google/go-cmp v0.6.0
 	github.com/lmittmann/tint v1.1.3 // gomodjail:unconfined
 	github.com/spf13/cobra v1.10.2 // gomodjail:unconfined
 	github.com/spf13/pflag v1.0.10
@@ -21,7 +22,4 @@ require (
 	gotest.tools/v3 v3.5.2
 )
 
-require (
-	github.com/google/go-cmp v0.6.0 // indirect
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-)
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
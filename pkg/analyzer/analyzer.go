@@ -18,8 +18,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	gomoddirectivecomments "github.com/AkihiroSuda/gomoddirectivecomments"
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
 	"golang.org/x/tools/go/analysis"
@@ -34,6 +34,27 @@ type Resolver interface {
 	Lookup(ctx context.Context, sum string) ([]cache.Meta, error)
 }
 
+// BatchResolver is an optional extension of [Resolver] that resolves many sums
+// at once. When the configured resolver implements it, the analyzer resolves the
+// whole go.sum of each module in a single call. [*cache.Cache] implements it.
+type BatchResolver interface {
+	Resolver
+	// BatchLookup returns the lookup result of each sum. Sums that are not
+	// adopted by any trusted project may be omitted from the result.
+	BatchLookup(ctx context.Context, sums []string) (map[string][]cache.Meta, error)
+}
+
+// Explainer is an optional extension of [Resolver] used by [Opts.Explain].
+// [*cache.Cache] implements it.
+type Explainer interface {
+	Explain(ctx context.Context, modPath, version, sum string) (*cache.Explanation, error)
+}
+
+var (
+	_ BatchResolver = (*cache.Cache)(nil)
+	_ Explainer     = (*cache.Cache)(nil)
+)
+
 type Opts struct {
 	Flags flag.FlagSet
 	Cache Resolver
@@ -42,6 +63,23 @@ type Opts struct {
 	// when there are findings. See:
 	// https://docs.github.com/en/actions/reference/workflows-and-actions/workflow-commands
 	GHA bool
+	// Baseline, if set, suppresses the findings recorded in it. Entries that no
+	// longer match any finding are reported as stale by Flush.
+	Baseline *Baseline
+	// WriteBaseline, if set, names a file to which Flush writes every finding as
+	// a [Baseline] instead of reporting it.
+	WriteBaseline string
+	// Explain prints, for every checked module, the trusted projects that use it
+	// or, on a miss, the other versions of the module used by trusted projects.
+	// Requires the Cache to implement [Explainer].
+	Explain bool
+	// Match selects how closely a trusted project's version has to match. The
+	// zero value is [MatchExact]. Other levels require the Cache to implement
+	// [ModuleResolver].
+	Match Match
+	// Format selects how findings are emitted. The zero value is [FormatText].
+	// Structured formats cannot be combined with GHA.
+	Format Format
 	// OnProgress, if set, receives progress events (e.g. while fetching the base
 	// branch in --gha mode).
 	OnProgress progress.Handler
@@ -82,6 +120,9 @@ func New(ctx context.Context, opts Opts) (*Analyzer, error) {
 		changedSumLines:     make(map[string]map[int]struct{}),
 		changedSumLinesDone: make(map[string]bool),
 		mods:                make(map[string]*modInfo),
+		works:               make(map[string]*workspace),
+		resolved:            make(map[string][]cache.Meta),
+		importSites:         make(map[module.Version][]token.Position),
 	}
 	a := &analysis.Analyzer{
 		Name:             "gosocialcheck",
@@ -108,16 +149,43 @@ type instance struct {
 	changedSumLines     map[string]map[int]struct{}
 	changedSumLinesDone map[string]bool
 
-	// ghaFindings buffers findings in --gha mode so they can be prioritized and
-	// capped before being emitted by flushGHA.
-	ghaFindingsMu sync.Mutex
-	ghaFindings   []ghaFinding
+	// findings buffers findings in --gha mode (and with a structured Format)
+	// so they can be prioritized and capped (or sorted) before being emitted by
+	// flushGHA (or flushStructured).
+	findingsMu sync.Mutex
+	findings   []finding
+
+	// importSites records, per module version, every import site of the
+	// module for structured output. Not keyed by the h1 sum, as the sum is
+	// empty when the module is missing in go.sum.
+	importSitesMu sync.Mutex
+	importSites   map[module.Version][]token.Position
+
+	// explainMu serializes the explanations written by Opts.Explain.
+	explainMu sync.Mutex
+
+	// now returns the current time for evaluating until= directive arguments.
+	// nil means [time.Now].
+	now func() time.Time
+
+	// newBaseline collects the findings to write with Opts.WriteBaseline.
+	newBaselineMu sync.Mutex
+	newBaseline   []BaselineEntry
 
 	// mods records the parsed go.mod of each analyzed module, keyed by its go.mod
 	// filename. Flush iterates these require lists to check indirect dependencies,
 	// which never appear as imports in the analyzed source.
 	modsMu sync.Mutex
 	mods   map[string]*modInfo
+
+	// works caches the parsed go.work workspaces, keyed by go.work filename.
+	worksMu sync.Mutex
+	works   map[string]*workspace
+
+	// resolved memoizes the results prefetched via [BatchResolver], keyed by
+	// h1 sum. A present key with a nil value is a known miss.
+	resolvedMu sync.Mutex
+	resolved   map[string][]cache.Meta
 }
 
 // modInfo holds the parsed state of a single module needed to check its
@@ -128,62 +196,52 @@ type modInfo struct {
 	goMod         *modfile.File
 	goSum         map[string]goSumEntry
 	policies      map[string]string
+	// work is the go.work workspace the module is a member of, or nil.
+	work *workspace
 }
 
-type ghaFinding struct {
+const (
+	findingKindDirect   = "direct"
+	findingKindIndirect = "indirect"
+)
+
+// finding is a module that does not seem adopted by a trusted project.
+type finding struct {
 	msg            string
-	sumPosn        token.Position // go.sum line to annotate (--gha mode)
-	modPosn        token.Position // go.mod line to report (non-gha indirect findings)
-	changedInPR    bool           // go.sum line was added/changed in the pull request
-	changeSetKnown bool           // the pull request change set for the go.sum file was determinable
+	mod            module.Version   // effective (replaced) module version
+	match          Match            // match level used for the lookup
+	sum            string           // h1 sum of mod
+	kind           string           // findingKindDirect or findingKindIndirect
+	policy         string           // gosocialcheck directive policy that applied to mod
+	importPosns    []token.Position // import sites (structured output only)
+	sumPosn        token.Position   // go.sum line to annotate (--gha mode)
+	modPosn        token.Position   // go.mod line to report (non-gha indirect findings)
+	changedInPR    bool             // go.sum line was added/changed in the pull request
+	changeSetKnown bool             // the pull request change set for the go.sum file was determinable
 }
 
 func run(ctx context.Context, inst *instance) func(*analysis.Pass) (any, error) {
 	return func(pass *analysis.Pass) (any, error) {
-		// TODO: cache go.mod
-		// TODO: support multi-module mono repo
 		goModFilename := pass.Module.GoMod
 		if goModFilename == "" {
 			return nil, nil
 		}
-		// pass.ReadFile does not support go.mod
-		goModB, err := os.ReadFile(goModFilename)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read %q: %w", goModFilename, err)
-		}
-		goMod, err := modfile.Parse(goModFilename, goModB, nil)
+		// Record the module (and, in a go.work workspace, every member module)
+		// so Flush can check indirect dependencies, which are listed in go.mod
+		// but never imported by the analyzed source.
+		mi, err := inst.loadModule(goModFilename)
 		if err != nil {
 			return nil, err
 		}
+		goMod := mi.goMod
 		if goMod.Module.Mod.Path != pass.Module.Path {
 			return nil, fmt.Errorf("%s: expected %q, got %q", goModFilename, pass.Module.Path, goMod.Module.Mod.Path)
 		}
-		policies, err := gomoddirectivecomments.Parse(goMod, "gosocialcheck", directivePolicyUntrusted)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse gosocialcheck directives in %q: %w", goModFilename, err)
-		}
-		// TODO: cache go.sum
-		goSumFilename := filepath.Join(filepath.Dir(goModFilename), "go.sum")
-		// pass.ReadFile does not support go.sum
-		goSumB, err := os.ReadFile(goSumFilename)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read %q: %w", goSumFilename, err)
-		}
-		goSum, err := parseGoSum(bytes.NewReader(goSumB))
-		if err != nil {
+		policies := mi.policies
+		if err = inst.resolveModule(ctx, mi); err != nil {
 			return nil, err
 		}
 
-		// Record the module so Flush can check its indirect dependencies, which
-		// are listed in go.mod but never imported by the analyzed source.
-		inst.recordModule(&modInfo{
-			goModFilename: goModFilename,
-			goSumFilename: goSumFilename,
-			goMod:         goMod,
-			goSum:         goSum,
-			policies:      policies,
-		})
-
 		for _, file := range pass.Files {
 			for _, imp := range file.Imports {
 				if imp.Path == nil {
@@ -193,17 +251,24 @@ func run(ctx context.Context, inst *instance) func(*analysis.Pass) (any, error)
 				if err != nil {
 					return nil, err
 				}
-				modV := moduleVersion(goMod, p)
+				req := requiredModule(goMod, p)
+				var modV *module.Version
+				if req != nil {
+					modV = mi.resolve(req.Mod)
+				}
 				if modV == nil {
 					slog.DebugContext(ctx, "module entry not found (negligible for stdlib and local imports)", "path", p)
 					continue
 				}
-				if policies[modV.Path] == directivePolicyTrusted {
-					slog.DebugContext(ctx, "module marked as trusted via gosocialcheck:trusted directive", "path", modV.Path)
+				policy, trusted, note := inst.checkPolicy(ctx, policies[modV.Path], *modV)
+				if trusted {
 					continue
 				}
-				goSumE := goSum[modV.Path+" "+modV.Version]
+				goSumE := mi.lookupSum(*modV)
 				h1 := goSumE.H1
+				if inst.structured() {
+					inst.addImportSite(*modV, pass.Fset.Position(imp.Pos()))
+				}
 				inst.processedSumsMu.RLock()
 				_, h1Processed := inst.processedSums[h1]
 				inst.processedSumsMu.RUnlock()
@@ -214,36 +279,56 @@ func run(ctx context.Context, inst *instance) func(*analysis.Pass) (any, error)
 				inst.processedSums[h1] = struct{}{}
 				inst.processedSumsMu.Unlock()
 				slog.DebugContext(ctx, "module", "path", p, "modpath", modV.Path, "modver", modV.Version, "h1", h1)
-				hit, err := inst.Opts.Cache.Lookup(ctx, h1)
+				hit, err := inst.lookupModule(ctx, *modV, h1)
 				if err != nil {
 					return nil, err
 				}
+				if err = inst.explain(ctx, *modV, h1); err != nil {
+					return nil, err
+				}
 				if len(hit) == 0 {
-					msg := fmt.Sprintf("import '%s': module '%s' does not seem adopted by a trusted project "+
-						"(negligible if you trust the module)",
-						p, modV.String())
-					if inst.Opts.GHA {
+					msg := fmt.Sprintf("import '%s': module '%s' does not seem adopted by a trusted project (%s)",
+						p, modV.String(), note)
+					f := finding{
+						msg:    msg,
+						mod:    *modV,
+						match:  inst.match(),
+						sum:    h1,
+						kind:   findingKindDirect,
+						policy: policy,
+						modPosn: token.Position{
+							Filename: goModFilename,
+							Line:     requireLine(req),
+							Column:   1,
+						},
+					}
+					if goSumE.Line > 0 {
+						f.sumPosn = token.Position{
+							Filename: goSumE.Filename,
+							Line:     goSumE.Line,
+							Column:   1,
+						}
+					}
+					if inst.baselined(ctx, f) {
+						continue
+					}
+					switch {
+					case inst.Opts.GHA:
 						// Annotate the go.sum line only. If the module has no
 						// go.sum entry there is nothing to annotate.
 						if goSumE.Line > 0 {
-							f := ghaFinding{
-								msg: msg,
-								sumPosn: token.Position{
-									Filename: goSumFilename,
-									Line:     goSumE.Line,
-									Column:   1,
-								},
-							}
-							if changed, ok := inst.changedGoSumLines(ctx, goSumFilename); ok {
+							if changed, ok := inst.changedGoSumLines(ctx, goSumE.Filename); ok {
 								f.changeSetKnown = true
 								_, f.changedInPR = changed[goSumE.Line]
 							}
-							inst.addGHAFinding(f)
+							inst.addFinding(f)
 						} else {
 							slog.DebugContext(ctx, "no go.sum line for module; skipping GHA annotation",
 								"path", p, "modpath", modV.Path)
 						}
-					} else {
+					case inst.structured():
+						inst.addFinding(f)
+					default:
 						pass.Report(analysis.Diagnostic{
 							Pos:     imp.Pos(),
 							End:     imp.End(),
@@ -277,17 +362,30 @@ func (inst *instance) recordModule(mi *modInfo) {
 // flush checks indirect dependencies and emits findings. In --gha mode the
 // indirect findings join the buffered direct findings before being prioritized,
 // capped, and emitted as workflow commands; the returned count is 0 because
-// --gha always exits 0. Outside --gha mode the indirect findings are printed and
-// the returned count feeds the diagnostic exit code (direct findings are emitted
-// separately by the analysis framework).
+// --gha always exits 0. With a structured [Format] all findings are buffered and
+// written to stdout, and the returned count covers all of them. Otherwise the
+// indirect findings are printed and the returned count feeds the diagnostic exit
+// code (direct findings are emitted separately by the analysis framework).
 func (inst *instance) flush(ctx context.Context) (int, error) {
 	findings, err := inst.collectIndirect(ctx)
-	if inst.Opts.GHA {
+	if err == nil {
+		// The baseline is not written, nor its stale entries reported,
+		// when the lookup of the indirect dependencies failed midway.
+		err = inst.flushBaseline(ctx)
+	}
+	switch {
+	case inst.Opts.GHA:
 		for _, f := range findings {
-			inst.addGHAFinding(f)
+			inst.addFinding(f)
 		}
 		inst.flushGHA(ctx)
 		return 0, err
+	case inst.structured():
+		for _, f := range findings {
+			inst.addFinding(f)
+		}
+		n, wErr := inst.flushStructured(os.Stdout)
+		return n, errors.Join(err, wErr)
 	}
 	for _, f := range findings {
 		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", f.modPosn.Filename, f.modPosn.Line, f.modPosn.Column, f.msg)
@@ -300,7 +398,7 @@ func (inst *instance) flush(ctx context.Context) (int, error) {
 // and was not already reported via an import site (tracked in processedSums).
 // This covers indirect dependencies, which never appear as imports in the
 // analyzed source.
-func (inst *instance) collectIndirect(ctx context.Context) ([]ghaFinding, error) {
+func (inst *instance) collectIndirect(ctx context.Context) ([]finding, error) {
 	inst.modsMu.Lock()
 	names := make([]string, 0, len(inst.mods))
 	for name := range inst.mods {
@@ -313,18 +411,24 @@ func (inst *instance) collectIndirect(ctx context.Context) ([]ghaFinding, error)
 	}
 	inst.modsMu.Unlock()
 
-	var res []ghaFinding
+	for _, mi := range mods {
+		if err := inst.resolveModule(ctx, mi); err != nil {
+			return nil, err
+		}
+	}
+
+	var res []finding
 	for _, mi := range mods {
 		for _, r := range mi.goMod.Require {
-			modV := resolveReplace(mi.goMod, r.Mod)
+			modV := mi.resolve(r.Mod)
 			if modV == nil {
 				continue
 			}
-			if mi.policies[modV.Path] == directivePolicyTrusted {
-				slog.DebugContext(ctx, "module marked as trusted via gosocialcheck:trusted directive", "path", modV.Path)
+			policy, trusted, note := inst.checkPolicy(ctx, mi.policies[modV.Path], *modV)
+			if trusted {
 				continue
 			}
-			goSumE := mi.goSum[modV.Path+" "+modV.Version]
+			goSumE := mi.lookupSum(*modV)
 			h1 := goSumE.H1
 			if h1 == "" {
 				slog.DebugContext(ctx, "no go.sum entry for required module; skipping", "path", modV.Path, "version", modV.Version)
@@ -340,22 +444,30 @@ func (inst *instance) collectIndirect(ctx context.Context) ([]ghaFinding, error)
 				// Already reported via an import site (direct dependency).
 				continue
 			}
-			hit, err := inst.Opts.Cache.Lookup(ctx, h1)
+			hit, err := inst.lookupModule(ctx, *modV, h1)
 			if err != nil {
 				return res, err
 			}
+			if err = inst.explain(ctx, *modV, h1); err != nil {
+				return res, err
+			}
 			if len(hit) > 0 {
 				slog.DebugContext(ctx, "cache hit", "path", modV.Path, "hit[0]", hit[0])
 				continue
 			}
-			kind := "dependency"
+			kind, kindMsg := findingKindDirect, "dependency"
 			if r.Indirect {
-				kind = "indirect dependency"
+				kind, kindMsg = findingKindIndirect, "indirect dependency"
 			}
-			msg := fmt.Sprintf("module '%s' (%s) does not seem adopted by a trusted project "+
-				"(negligible if you trust the module)", modV.String(), kind)
-			f := ghaFinding{
-				msg: msg,
+			msg := fmt.Sprintf("module '%s' (%s) does not seem adopted by a trusted project (%s)",
+				modV.String(), kindMsg, note)
+			f := finding{
+				msg:    msg,
+				mod:    *modV,
+				match:  inst.match(),
+				sum:    h1,
+				kind:   kind,
+				policy: policy,
 				// Non-GHA findings point at the go.mod require line; GHA findings
 				// annotate the go.sum line (set below when available).
 				modPosn: token.Position{
@@ -364,17 +476,22 @@ func (inst *instance) collectIndirect(ctx context.Context) ([]ghaFinding, error)
 					Column:   1,
 				},
 			}
+			if goSumE.Line > 0 {
+				f.sumPosn = token.Position{
+					Filename: goSumE.Filename,
+					Line:     goSumE.Line,
+					Column:   1,
+				}
+			}
+			if inst.baselined(ctx, f) {
+				continue
+			}
 			if inst.Opts.GHA {
 				if goSumE.Line == 0 {
 					slog.DebugContext(ctx, "no go.sum line for module; skipping GHA annotation", "path", modV.Path)
 					continue
 				}
-				f.sumPosn = token.Position{
-					Filename: mi.goSumFilename,
-					Line:     goSumE.Line,
-					Column:   1,
-				}
-				if changed, ok := inst.changedGoSumLines(ctx, mi.goSumFilename); ok {
+				if changed, ok := inst.changedGoSumLines(ctx, goSumE.Filename); ok {
 					f.changeSetKnown = true
 					_, f.changedInPR = changed[goSumE.Line]
 				}
@@ -385,6 +502,117 @@ func (inst *instance) collectIndirect(ctx context.Context) ([]ghaFinding, error)
 	return res, nil
 }
 
+// checkPolicy evaluates the gosocialcheck directive policy raw (as parsed from
+// go.mod) for modV. It returns the policy in its go.mod form for reporting, whether
+// the directive trusts the module, and otherwise a note for the finding message.
+func (inst *instance) checkPolicy(ctx context.Context, raw string, modV module.Version) (string, bool, string) {
+	const defaultNote = "negligible if you trust the module"
+	pol, err := parsePolicy(raw)
+	if err != nil {
+		// Unreachable for policies validated by readModule.
+		slog.WarnContext(ctx, "invalid gosocialcheck directive", "path", modV.Path, "policy", raw, "error", err)
+		return raw, false, defaultNote
+	}
+	now := time.Now()
+	if inst.now != nil {
+		now = inst.now()
+	}
+	trusted, note := pol.check(modV.Version, now)
+	if trusted {
+		slog.DebugContext(ctx, "module marked as trusted via gosocialcheck:trusted directive", "path", modV.Path, "policy", pol.String())
+		return pol.String(), true, ""
+	}
+	if note == "" {
+		note = defaultNote
+	} else {
+		slog.DebugContext(ctx, "gosocialcheck:trusted directive does not apply", "path", modV.Path, "version", modV.Version, "reason", note)
+	}
+	if m := inst.match(); m != MatchExact {
+		note = fmt.Sprintf("match=%s; %s", m, note)
+	}
+	return pol.String(), false, note
+}
+
+// explain writes the explanation of modV (with h1 sum h1) to stderr when
+// Opts.Explain is set.
+func (inst *instance) explain(ctx context.Context, modV module.Version, h1 string) error {
+	if !inst.Opts.Explain {
+		return nil
+	}
+	ex, ok := inst.Opts.Cache.(Explainer)
+	if !ok {
+		return errors.New("the resolver does not support explanations")
+	}
+	e, err := ex.Explain(ctx, modV.Path, modV.Version, h1)
+	if err != nil {
+		return err
+	}
+	inst.explainMu.Lock()
+	defer inst.explainMu.Unlock()
+	return e.WriteText(os.Stderr)
+}
+
+// resolveModule prefetches the lookup results of every module sum in the go.sum
+// of mi (and, in workspace mode, the sums shared by the workspace) in a single
+// [BatchResolver.BatchLookup] call. It is a no-op when the resolver does not
+// implement [BatchResolver] or when every sum has already been resolved.
+func (inst *instance) resolveModule(ctx context.Context, mi *modInfo) error {
+	br, ok := inst.Opts.Cache.(BatchResolver)
+	if !ok {
+		return nil
+	}
+	goSums := []map[string]goSumEntry{mi.goSum}
+	if mi.work != nil {
+		goSums = append(goSums, mi.work.goSum)
+	}
+	var sums []string
+	seen := make(map[string]struct{})
+	inst.resolvedMu.Lock()
+	for _, goSum := range goSums {
+		for k, e := range goSum {
+			// "<path> <version>/go.mod" entries are never looked up.
+			if strings.HasSuffix(k, "/go.mod") || !strings.HasPrefix(e.H1, "h1:") {
+				continue
+			}
+			if _, ok := inst.resolved[e.H1]; ok {
+				continue
+			}
+			if _, ok := seen[e.H1]; ok {
+				continue
+			}
+			seen[e.H1] = struct{}{}
+			sums = append(sums, e.H1)
+		}
+	}
+	inst.resolvedMu.Unlock()
+	if len(sums) == 0 {
+		return nil
+	}
+	sort.Strings(sums)
+	hits, err := br.BatchLookup(ctx, sums)
+	if err != nil {
+		return err
+	}
+	inst.resolvedMu.Lock()
+	for _, sum := range sums {
+		inst.resolved[sum] = hits[sum]
+	}
+	inst.resolvedMu.Unlock()
+	return nil
+}
+
+// lookup resolves sum, using the result prefetched by resolveModule when
+// available.
+func (inst *instance) lookup(ctx context.Context, sum string) ([]cache.Meta, error) {
+	inst.resolvedMu.Lock()
+	hit, ok := inst.resolved[sum]
+	inst.resolvedMu.Unlock()
+	if ok {
+		return hit, nil
+	}
+	return inst.Opts.Cache.Lookup(ctx, sum)
+}
+
 // requireLine returns the 1-based go.mod line of a require entry, or 0 if it is
 // not available.
 func requireLine(r *modfile.Require) int {
@@ -394,20 +622,20 @@ func requireLine(r *modfile.Require) int {
 	return 0
 }
 
-func (inst *instance) addGHAFinding(f ghaFinding) {
-	inst.ghaFindingsMu.Lock()
-	inst.ghaFindings = append(inst.ghaFindings, f)
-	inst.ghaFindingsMu.Unlock()
+func (inst *instance) addFinding(f finding) {
+	inst.findingsMu.Lock()
+	inst.findings = append(inst.findings, f)
+	inst.findingsMu.Unlock()
 }
 
 func (inst *instance) flushGHA(ctx context.Context) {
 	if !inst.Opts.GHA {
 		return
 	}
-	inst.ghaFindingsMu.Lock()
-	findings := inst.ghaFindings
-	inst.ghaFindings = nil
-	inst.ghaFindingsMu.Unlock()
+	inst.findingsMu.Lock()
+	findings := inst.findings
+	inst.findings = nil
+	inst.findingsMu.Unlock()
 
 	// On a pull request, annotate only the modules actually added/changed in the
 	// PR: warnings for untouched modules are just noise and waste the limited
@@ -584,38 +812,44 @@ func parseDiffNewLines(diff string) map[int]struct{} {
 	return res
 }
 
-func moduleVersion(goMod *modfile.File, imp string) *module.Version {
-	// Find the require entry for this import
-	var reqMod *module.Version
+// requiredModule returns the require entry of goMod that provides the package
+// imp, or nil if there is none.
+func requiredModule(goMod *modfile.File, imp string) *modfile.Require {
 	for _, r := range goMod.Require {
 		// TODO: check multiple matches
 		if r.Mod.Path == imp || strings.HasPrefix(imp, r.Mod.Path+"/") {
-			reqMod = &r.Mod
-			break
+			return r
 		}
 	}
-	if reqMod == nil {
-		return nil
-	}
-	return resolveReplace(goMod, *reqMod)
+	return nil
 }
 
 // resolveReplace applies any matching replace directive to reqMod and returns
 // the effective module version to check. It returns nil when the module is
 // replaced by a local path (which has no go.sum entry).
 func resolveReplace(goMod *modfile.File, reqMod module.Version) *module.Version {
-	for _, r := range goMod.Replace {
+	if modV, ok := applyReplace(goMod.Replace, reqMod); ok {
+		return modV
+	}
+	return &reqMod
+}
+
+// applyReplace applies the first matching replace directive in replaces to
+// reqMod. The second return value reports whether a directive matched; the
+// returned version is nil when the replacement is a local path.
+func applyReplace(replaces []*modfile.Replace, reqMod module.Version) (*module.Version, bool) {
+	for _, r := range replaces {
 		// Match if: same path AND (replace has no version OR versions match)
 		if r.Old.Path == reqMod.Path && (r.Old.Version == "" || r.Old.Version == reqMod.Version) {
 			// Local path replacements have no go.sum entry
 			if isLocalPath(r.New.Path) {
-				return nil
+				return nil, true
 			}
 			newMod := r.New
-			return &newMod
+			return &newMod, true
 		}
 	}
-	return &reqMod
+	return nil, false
 }
 
 func isLocalPath(path string) bool {
@@ -658,6 +892,27 @@ func emitGHAWarning(cwd string, posn, endPosn token.Position, msg string) {
 type goSumEntry struct {
 	H1   string
 	Line int // 1-based line number in go.sum
+	// Filename is the go.sum (or go.work.sum) file the entry was read from.
+	// It is only set by [readGoSum] and [modInfo.lookupSum].
+	Filename string
+}
+
+// readGoSum parses the go.sum (or go.work.sum) file at filename.
+func readGoSum(filename string) (map[string]goSumEntry, error) {
+	// pass.ReadFile does not support go.sum
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	res, err := parseGoSum(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", filename, err)
+	}
+	for k, e := range res {
+		e.Filename = filename
+		res[k] = e
+	}
+	return res, nil
 }
 
 func parseGoSum(r io.Reader) (map[string]goSumEntry, error) {
@@ -3,6 +3,7 @@ package analyzer
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"go/token"
 	"io"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
@@ -43,7 +45,7 @@ func TestFlushGHAPrioritizesAndCaps(t *testing.T) {
 
 	t.Run("only go.sum is annotated", func(t *testing.T) {
 		inst := &instance{Opts: Opts{GHA: true}, cwd: "/repo"}
-		inst.addGHAFinding(ghaFinding{msg: "m", sumPosn: sumPosn(3)})
+		inst.addFinding(finding{msg: "m", sumPosn: sumPosn(3)})
 		out := captureStdout(t, func() { inst.flushGHA(context.Background()) })
 		lines := strings.Split(strings.TrimSpace(out), "\n")
 		assert.Equal(t, 1, len(lines))
@@ -53,8 +55,8 @@ func TestFlushGHAPrioritizesAndCaps(t *testing.T) {
 
 	t.Run("changed-in-PR findings emitted first", func(t *testing.T) {
 		inst := &instance{Opts: Opts{GHA: true}, cwd: "/repo"}
-		inst.addGHAFinding(ghaFinding{msg: "other-finding", sumPosn: sumPosn(1)})
-		inst.addGHAFinding(ghaFinding{msg: "priority-finding", sumPosn: sumPosn(9), changedInPR: true})
+		inst.addFinding(finding{msg: "other-finding", sumPosn: sumPosn(1)})
+		inst.addFinding(finding{msg: "priority-finding", sumPosn: sumPosn(9), changedInPR: true})
 		out := captureStdout(t, func() { inst.flushGHA(context.Background()) })
 		lines := strings.Split(strings.TrimSpace(out), "\n")
 		assert.Equal(t, 2, len(lines))
@@ -65,7 +67,7 @@ func TestFlushGHAPrioritizesAndCaps(t *testing.T) {
 	t.Run("caps total annotations", func(t *testing.T) {
 		inst := &instance{Opts: Opts{GHA: true}, cwd: "/repo"}
 		for i := 0; i < 60; i++ {
-			inst.addGHAFinding(ghaFinding{msg: "m", sumPosn: sumPosn(i + 1)})
+			inst.addFinding(finding{msg: "m", sumPosn: sumPosn(i + 1)})
 		}
 		out := captureStdout(t, func() { inst.flushGHA(context.Background()) })
 		assert.Equal(t, ghaMaxAnnotations, strings.Count(out, "::warning"))
@@ -75,9 +77,9 @@ func TestFlushGHAPrioritizesAndCaps(t *testing.T) {
 		inst := &instance{Opts: Opts{GHA: true}, cwd: "/repo"}
 		// Fill well past the cap with unchanged findings, then add one changed.
 		for i := 0; i < 60; i++ {
-			inst.addGHAFinding(ghaFinding{msg: "other-finding", sumPosn: sumPosn(i + 1)})
+			inst.addFinding(finding{msg: "other-finding", sumPosn: sumPosn(i + 1)})
 		}
-		inst.addGHAFinding(ghaFinding{msg: "priority-finding", sumPosn: sumPosn(999), changedInPR: true})
+		inst.addFinding(finding{msg: "priority-finding", sumPosn: sumPosn(999), changedInPR: true})
 		out := captureStdout(t, func() { inst.flushGHA(context.Background()) })
 		assert.Assert(t, strings.Contains(out, "priority-finding"), "changed-in-PR finding must survive the cap")
 	})
@@ -85,8 +87,8 @@ func TestFlushGHAPrioritizesAndCaps(t *testing.T) {
 	t.Run("drops untouched modules when the PR change set is known", func(t *testing.T) {
 		inst := &instance{Opts: Opts{GHA: true}, cwd: "/repo"}
 		// Known change set, but module not touched in the PR: must be dropped.
-		inst.addGHAFinding(ghaFinding{msg: "untouched-finding", sumPosn: sumPosn(1), changeSetKnown: true})
-		inst.addGHAFinding(ghaFinding{msg: "touched-finding", sumPosn: sumPosn(2), changeSetKnown: true, changedInPR: true})
+		inst.addFinding(finding{msg: "untouched-finding", sumPosn: sumPosn(1), changeSetKnown: true})
+		inst.addFinding(finding{msg: "touched-finding", sumPosn: sumPosn(2), changeSetKnown: true, changedInPR: true})
 		out := captureStdout(t, func() { inst.flushGHA(context.Background()) })
 		lines := strings.Split(strings.TrimSpace(out), "\n")
 		assert.Equal(t, 1, len(lines))
@@ -97,7 +99,7 @@ func TestFlushGHAPrioritizesAndCaps(t *testing.T) {
 	t.Run("keeps findings when the change set is unknown", func(t *testing.T) {
 		inst := &instance{Opts: Opts{GHA: true}, cwd: "/repo"}
 		// Change set could not be determined (e.g. push event): keep the finding.
-		inst.addGHAFinding(ghaFinding{msg: "unknown-finding", sumPosn: sumPosn(1)})
+		inst.addFinding(finding{msg: "unknown-finding", sumPosn: sumPosn(1)})
 		out := captureStdout(t, func() { inst.flushGHA(context.Background()) })
 		assert.Assert(t, strings.Contains(out, "unknown-finding"), "finding must survive when change set is unknown: %q", out)
 	})
@@ -195,7 +197,11 @@ require github.com/bar/baz v1.0.0
 			goMod, err := modfile.Parse("go.mod", []byte(tt.goMod), nil)
 			assert.NilError(t, err)
 
-			got := moduleVersion(goMod, tt.imp)
+			// Same as the lookup of the import sites in run.
+			var got *module.Version
+			if req := requiredModule(goMod, tt.imp); req != nil {
+				got = (&modInfo{goMod: goMod}).resolve(req.Mod)
+			}
 			if tt.expected == nil {
 				assert.Assert(t, got == nil, "expected nil, got %v", got)
 			} else {
@@ -224,7 +230,33 @@ func newInstanceForTest(t *testing.T, gha bool, resolver Resolver) *instance {
 		changedSumLines:     make(map[string]map[int]struct{}),
 		changedSumLinesDone: make(map[string]bool),
 		mods:                make(map[string]*modInfo),
+		works:               make(map[string]*workspace),
+		resolved:            make(map[string][]cache.Meta),
+		importSites:         make(map[module.Version][]token.Position),
+	}
+}
+
+// fakeBatchResolver is a [BatchResolver] that records how it was called.
+type fakeBatchResolver struct {
+	fakeResolver
+	lookups      int
+	batchLookups [][]string
+}
+
+func (f *fakeBatchResolver) Lookup(ctx context.Context, sum string) ([]cache.Meta, error) {
+	f.lookups++
+	return f.fakeResolver.Lookup(ctx, sum)
+}
+
+func (f *fakeBatchResolver) BatchLookup(_ context.Context, sums []string) (map[string][]cache.Meta, error) {
+	f.batchLookups = append(f.batchLookups, sums)
+	res := make(map[string][]cache.Meta)
+	for _, sum := range sums {
+		if hit := f.hits[sum]; len(hit) > 0 {
+			res[sum] = hit
+		}
 	}
+	return res, nil
 }
 
 func TestCollectIndirect(t *testing.T) {
@@ -301,6 +333,23 @@ example.com/indirect-trusted-adopted v1.3.0 h1:adopted=
 		assert.Equal(t, 2, f.sumPosn.Line) // line of indirect-untrusted in go.sum
 	})
 
+	t.Run("batch resolver resolves the whole go.sum at once", func(t *testing.T) {
+		br := &fakeBatchResolver{fakeResolver: *resolver}
+		inst := newInstanceForTest(t, false, br)
+		inst.recordModule(mi)
+		inst.processedSums["h1:direct="] = struct{}{}
+
+		findings, err := inst.collectIndirect(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(findings))
+		assert.Equal(t, 0, br.lookups)
+		assert.DeepEqual(t, [][]string{{"h1:adopted=", "h1:direct=", "h1:untrusted="}}, br.batchLookups)
+
+		// Already-resolved sums are not looked up again.
+		assert.NilError(t, inst.resolveModule(context.Background(), mi))
+		assert.Equal(t, 1, len(br.batchLookups))
+	})
+
 	t.Run("already-processed direct deps are skipped", func(t *testing.T) {
 		inst := newInstanceForTest(t, false, resolver)
 		inst.recordModule(mi)
@@ -314,6 +363,524 @@ example.com/indirect-trusted-adopted v1.3.0 h1:adopted=
 	})
 }
 
+func writeFileT(t *testing.T, name, content string) {
+	t.Helper()
+	assert.NilError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+	assert.NilError(t, os.WriteFile(name, []byte(content), 0o644))
+}
+
+func TestWorkspace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	root := t.TempDir()
+	writeFileT(t, filepath.Join(root, "go.work"), `go 1.25.0
+
+use (
+	./a
+	./b
+)
+
+replace example.com/replaced => example.com/fork v1.0.1
+`)
+	writeFileT(t, filepath.Join(root, "go.work.sum"), `example.com/only-in-work-sum v1.0.0 h1:worksum=
+`)
+	writeFileT(t, filepath.Join(root, "a", "go.mod"), `module example.com/a
+
+go 1.25.0
+
+require (
+	example.com/b v0.0.0
+	example.com/replaced v1.0.0
+	example.com/only-in-work-sum v1.0.0 // indirect
+)
+`)
+	writeFileT(t, filepath.Join(root, "a", "go.sum"), `example.com/fork v1.0.1 h1:fork=
+example.com/shared v1.0.0 h1:shared=
+`)
+	// b has no go.sum of its own: its sums live in a's go.sum and go.work.sum.
+	writeFileT(t, filepath.Join(root, "b", "go.mod"), `module example.com/b
+
+go 1.25.0
+
+require example.com/shared v1.0.0
+`)
+
+	inst := newInstanceForTest(t, true, &fakeResolver{})
+	mi, err := inst.loadModule(filepath.Join(root, "a", "go.mod"))
+	assert.NilError(t, err)
+	assert.Assert(t, mi.work != nil)
+	// Every member is recorded, not just the loaded one.
+	assert.Equal(t, 2, len(inst.mods))
+
+	// Workspace members resolve to the local copy.
+	req := requiredModule(mi.goMod, "example.com/b/pkg")
+	assert.Assert(t, req != nil)
+	assert.Assert(t, mi.resolve(req.Mod) == nil)
+	// go.work replace directives apply.
+	req = requiredModule(mi.goMod, "example.com/replaced")
+	assert.Assert(t, req != nil)
+	modV := mi.resolve(req.Mod)
+	assert.Assert(t, modV != nil)
+	assert.Equal(t, "example.com/fork@v1.0.1", modV.String())
+
+	findings, err := inst.collectIndirect(context.Background())
+	assert.NilError(t, err)
+	got := make(map[string]finding)
+	for _, f := range findings {
+		got[filepath.Base(filepath.Dir(f.modPosn.Filename))+" "+filepath.Base(f.sumPosn.Filename)] = f
+	}
+	assert.Equal(t, 3, len(findings), "findings: %+v", findings)
+	// Each finding is attributed to the member that requires the dependency,
+	// and annotates the file that holds its sum.
+	f, ok := got["a go.sum"]
+	assert.Assert(t, ok, "findings: %+v", findings)
+	assert.Assert(t, strings.Contains(f.msg, "example.com/fork@v1.0.1"), "msg: %q", f.msg)
+	f, ok = got["a go.work.sum"]
+	assert.Assert(t, ok, "findings: %+v", findings)
+	assert.Assert(t, strings.Contains(f.msg, "example.com/only-in-work-sum@v1.0.0"), "msg: %q", f.msg)
+	f, ok = got["b go.sum"]
+	assert.Assert(t, ok, "findings: %+v", findings)
+	assert.Assert(t, strings.Contains(f.msg, "example.com/shared@v1.0.0"), "msg: %q", f.msg)
+	assert.Equal(t, filepath.Join(root, "a", "go.sum"), f.sumPosn.Filename)
+}
+
+func TestWorkspaceBuildList(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	root := t.TempDir()
+	writeFileT(t, filepath.Join(root, "go.work"), "go 1.25.0\n\nuse (\n\t./a\n\t./b\n)\n")
+	writeFileT(t, filepath.Join(root, "a", "go.mod"), "module example.com/a\n\ngo 1.25.0\n\nrequire example.com/shared v1.0.0\n")
+	writeFileT(t, filepath.Join(root, "a", "go.sum"), "example.com/shared v1.0.0 h1:shared100=\n")
+	writeFileT(t, filepath.Join(root, "b", "go.mod"), "module example.com/b\n\ngo 1.25.0\n\nrequire example.com/shared v1.2.0\n")
+	writeFileT(t, filepath.Join(root, "b", "go.sum"), "example.com/shared v1.2.0 h1:shared120=\n")
+
+	// Only the version required by a is adopted, but the workspace builds the
+	// version required by b.
+	inst := newInstanceForTest(t, false, &fakeResolver{hits: map[string][]cache.Meta{
+		"h1:shared100=": {{Category: "cncf.io::graduated"}},
+	}})
+	mi, err := inst.loadModule(filepath.Join(root, "a", "go.mod"))
+	assert.NilError(t, err)
+	modV := mi.resolve(module.Version{Path: "example.com/shared", Version: "v1.0.0"})
+	assert.Assert(t, modV != nil)
+	assert.Equal(t, "example.com/shared@v1.2.0", modV.String())
+
+	findings, err := inst.collectIndirect(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(findings), "findings: %+v", findings)
+	assert.Equal(t, "example.com/shared@v1.2.0", findings[0].mod.String())
+	assert.Equal(t, "h1:shared120=", findings[0].sum)
+}
+
+func TestWorkspaceOff(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	root := t.TempDir()
+	writeFileT(t, filepath.Join(root, "go.work"), "go 1.25.0\n\nuse ./a\n")
+	writeFileT(t, filepath.Join(root, "a", "go.mod"), "module example.com/a\n\ngo 1.25.0\n")
+	writeFileT(t, filepath.Join(root, "a", "go.sum"), "")
+
+	inst := newInstanceForTest(t, false, &fakeResolver{})
+	mi, err := inst.loadModule(filepath.Join(root, "a", "go.mod"))
+	assert.NilError(t, err)
+	assert.Assert(t, mi.work == nil)
+}
+
+func TestFlushStructured(t *testing.T) {
+	const goModSrc = `module example.com/foo
+
+go 1.25.0
+
+require example.com/direct v1.0.0
+
+require example.com/indirect v1.2.0 // indirect
+`
+	goMod, err := modfile.Parse("/repo/go.mod", []byte(goModSrc), nil)
+	assert.NilError(t, err)
+	goSum, err := parseGoSum(strings.NewReader("example.com/direct v1.0.0 h1:direct=\nexample.com/indirect v1.2.0 h1:indirect=\n"))
+	assert.NilError(t, err)
+	mi := &modInfo{
+		goModFilename: "/repo/go.mod",
+		goSumFilename: "/repo/go.sum",
+		goMod:         goMod,
+		goSum:         goSum,
+		policies:      map[string]string{},
+	}
+
+	for _, format := range []Format{FormatJSON, FormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			inst := newInstanceForTest(t, false, &fakeResolver{})
+			inst.Opts.Format = format
+			inst.recordModule(mi)
+			// Simulate a direct finding buffered during analysis, imported twice.
+			inst.processedSums["h1:direct="] = struct{}{}
+			inst.addFinding(finding{
+				msg:     "direct-finding",
+				mod:     module.Version{Path: "example.com/direct", Version: "v1.0.0"},
+				sum:     "h1:direct=",
+				kind:    findingKindDirect,
+				modPosn: token.Position{Filename: "/repo/go.mod", Line: 5, Column: 1},
+				sumPosn: token.Position{Filename: "/repo/go.sum", Line: 1, Column: 1},
+			})
+			inst.addImportSite(module.Version{Path: "example.com/direct", Version: "v1.0.0"}, token.Position{Filename: "/repo/b.go", Line: 4, Column: 2})
+			inst.addImportSite(module.Version{Path: "example.com/direct", Version: "v1.0.0"}, token.Position{Filename: "/repo/a.go", Line: 3, Column: 2})
+
+			var n int
+			out := captureStdout(t, func() {
+				n, err = inst.flush(context.Background())
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, 2, n)
+
+			var got []Finding
+			if format == FormatJSON {
+				assert.NilError(t, json.Unmarshal([]byte(out), &got))
+			} else {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				assert.Equal(t, 2, len(lines))
+				for _, line := range lines {
+					var f Finding
+					assert.NilError(t, json.Unmarshal([]byte(line), &f))
+					got = append(got, f)
+				}
+			}
+			assert.DeepEqual(t, []Finding{
+				{
+					Module:  "example.com/direct",
+					Version: "v1.0.0",
+					Sum:     "h1:direct=",
+					Kind:    "direct",
+					Match:   MatchExact,
+					Policy:  "untrusted",
+					Message: "direct-finding",
+					GoMod:   &Position{Filename: "/repo/go.mod", Line: 5, Column: 1},
+					GoSum:   &Position{Filename: "/repo/go.sum", Line: 1, Column: 1},
+					Imports: []Position{
+						{Filename: "/repo/a.go", Line: 3, Column: 2},
+						{Filename: "/repo/b.go", Line: 4, Column: 2},
+					},
+				},
+				{
+					Module:  "example.com/indirect",
+					Version: "v1.2.0",
+					Sum:     "h1:indirect=",
+					Kind:    "indirect",
+					Match:   MatchExact,
+					Policy:  "untrusted",
+					Message: "module 'example.com/indirect@v1.2.0' (indirect dependency) does not seem adopted by a trusted project (negligible if you trust the module)",
+					GoMod:   &Position{Filename: "/repo/go.mod", Line: 7, Column: 1},
+					GoSum:   &Position{Filename: "/repo/go.sum", Line: 2, Column: 1},
+				},
+			}, got)
+		})
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	const goModSrc = `module example.com/foo
+
+go 1.25.0
+
+require (
+	example.com/known v1.0.0 // indirect
+	example.com/new v1.0.0 // indirect
+)
+`
+	goMod, err := modfile.Parse("/repo/go.mod", []byte(goModSrc), nil)
+	assert.NilError(t, err)
+	goSum, err := parseGoSum(strings.NewReader("example.com/known v1.0.0 h1:known=\nexample.com/new v1.0.0 h1:new=\n"))
+	assert.NilError(t, err)
+	mi := &modInfo{
+		goModFilename: "/repo/go.mod",
+		goSumFilename: "/repo/go.sum",
+		goMod:         goMod,
+		goSum:         goSum,
+		policies:      map[string]string{},
+	}
+	baselineFile := filepath.Join(t.TempDir(), "baseline.json")
+
+	t.Run("write", func(t *testing.T) {
+		inst := newInstanceForTest(t, false, &fakeResolver{})
+		inst.Opts.WriteBaseline = baselineFile
+		inst.recordModule(mi)
+		n, err := inst.flush(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, 0, n)
+		bl, err := ReadBaseline(baselineFile)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, []BaselineEntry{
+			{Module: "example.com/known", Version: "v1.0.0", Sum: "h1:known="},
+			{Module: "example.com/new", Version: "v1.0.0", Sum: "h1:new="},
+		}, bl.Entries)
+	})
+
+	t.Run("suppress", func(t *testing.T) {
+		bl := &Baseline{
+			Version: baselineVersion,
+			Entries: []BaselineEntry{
+				{Module: "example.com/known", Version: "v1.0.0", Sum: "h1:known="},
+				{Module: "example.com/gone", Version: "v0.1.0", Sum: "h1:gone="},
+			},
+		}
+		inst := newInstanceForTest(t, false, &fakeResolver{})
+		inst.Opts.Baseline = bl
+		inst.recordModule(mi)
+		findings, err := inst.collectIndirect(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(findings))
+		assert.Equal(t, "example.com/new", findings[0].mod.Path)
+		assert.DeepEqual(t, []BaselineEntry{
+			{Module: "example.com/gone", Version: "v0.1.0", Sum: "h1:gone="},
+		}, bl.stale())
+	})
+
+	t.Run("a different sum is not suppressed", func(t *testing.T) {
+		bl := &Baseline{
+			Version: baselineVersion,
+			Entries: []BaselineEntry{
+				{Module: "example.com/known", Version: "v1.0.0", Sum: "h1:tampered="},
+			},
+		}
+		inst := newInstanceForTest(t, false, &fakeResolver{})
+		inst.Opts.Baseline = bl
+		inst.recordModule(mi)
+		findings, err := inst.collectIndirect(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, 2, len(findings))
+	})
+}
+
+func TestSARIF(t *testing.T) {
+	findings := []Finding{
+		{
+			Module:  "example.com/direct",
+			Version: "v1.0.0",
+			Kind:    findingKindDirect,
+			Message: "direct-finding",
+			GoMod:   &Position{Filename: "/repo/go.mod", Line: 5, Column: 1},
+			GoSum:   &Position{Filename: "/repo/go.sum", Line: 1, Column: 1},
+			Imports: []Position{{Filename: "/repo/a.go", Line: 3, Column: 2}},
+		},
+		{
+			Module:  "example.com/indirect",
+			Version: "v1.2.0",
+			Kind:    findingKindIndirect,
+			Message: "indirect-finding",
+			GoMod:   &Position{Filename: "/elsewhere/go.mod", Line: 7, Column: 1},
+		},
+	}
+	log := newSARIFLog("/repo", findings)
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Equal(t, 1, len(log.Runs))
+	run := log.Runs[0]
+	assert.Equal(t, 2, len(run.Results))
+
+	direct := run.Results[0]
+	assert.Equal(t, sarifRuleUntrustedDirect, direct.RuleID)
+	assert.Equal(t, sarifRuleUntrustedDirect, run.Tool.Driver.Rules[direct.RuleIndex].ID)
+	assert.Equal(t, "go.mod", direct.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "%SRCROOT%", direct.Locations[0].PhysicalLocation.ArtifactLocation.URIBaseID)
+	assert.Equal(t, 5, direct.Locations[0].PhysicalLocation.Region.StartLine)
+	assert.Equal(t, 2, len(direct.RelatedLocations))
+	assert.Equal(t, "go.sum", direct.RelatedLocations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "a.go", direct.RelatedLocations[1].PhysicalLocation.ArtifactLocation.URI)
+
+	indirect := run.Results[1]
+	assert.Equal(t, sarifRuleUntrustedIndirect, indirect.RuleID)
+	assert.Equal(t, sarifRuleUntrustedIndirect, run.Tool.Driver.Rules[indirect.RuleIndex].ID)
+	assert.Equal(t, "file:///elsewhere/go.mod", indirect.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+
+	// Fingerprints do not depend on line numbers.
+	moved := findings[0]
+	moved.GoMod = &Position{Filename: "/repo/go.mod", Line: 42, Column: 1}
+	assert.Equal(t, direct.PartialFingerprints[sarifFingerprintKey],
+		sarifFingerprint("/repo", sarifRuleUntrustedDirect, moved))
+	assert.Assert(t, direct.PartialFingerprints[sarifFingerprintKey] != indirect.PartialFingerprints[sarifFingerprintKey])
+}
+
+func TestVersionAwareDirectives(t *testing.T) {
+	root := t.TempDir()
+	goModFilename := filepath.Join(root, "go.mod")
+	writeFileT(t, goModFilename, `module example.com/foo
+
+go 1.25.0
+
+require (
+	example.com/reviewed v1.5.0 // indirect; gosocialcheck:trusted <=v1.4.0
+	example.com/in-range v1.2.0 //gosocialcheck:trusted >=v1.0.0 <v2.0.0
+	example.com/expired v1.0.0 //gosocialcheck:trusted until=2027-01-01
+	example.com/unconditional v1.0.0 //gosocialcheck:trusted
+)
+`)
+	writeFileT(t, filepath.Join(root, "go.sum"), `example.com/reviewed v1.5.0 h1:reviewed=
+example.com/in-range v1.2.0 h1:inrange=
+example.com/expired v1.0.0 h1:expired=
+example.com/unconditional v1.0.0 h1:unconditional=
+`)
+	mi, err := readModule(goModFilename, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{
+		"example.com/reviewed":      "trusted,<=v1.4.0",
+		"example.com/in-range":      "trusted,>=v1.0.0,<v2.0.0",
+		"example.com/expired":       "trusted,until=2027-01-01",
+		"example.com/unconditional": "trusted",
+	}, mi.policies)
+
+	collect := func(now time.Time) map[string]finding {
+		inst := newInstanceForTest(t, false, &fakeResolver{})
+		inst.now = func() time.Time { return now }
+		inst.recordModule(mi)
+		findings, err := inst.collectIndirect(context.Background())
+		assert.NilError(t, err)
+		res := make(map[string]finding)
+		for _, f := range findings {
+			res[f.mod.Path] = f
+		}
+		return res
+	}
+
+	got := collect(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 1, len(got), "findings: %+v", got)
+	f := got["example.com/reviewed"]
+	assert.Equal(t, "trusted <=v1.4.0", f.policy)
+	assert.Assert(t, strings.Contains(f.msg, "gosocialcheck:trusted <=v1.4.0 does not cover v1.5.0"), "msg: %q", f.msg)
+
+	got = collect(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 2, len(got), "findings: %+v", got)
+	f = got["example.com/expired"]
+	assert.Assert(t, strings.Contains(f.msg, "gosocialcheck:trusted until=2027-01-01 has expired"), "msg: %q", f.msg)
+}
+
+// fakeModuleResolver is a [ModuleResolver] backed by a static version map.
+type fakeModuleResolver struct {
+	fakeResolver
+	versions map[string]map[string][]cache.Meta
+}
+
+func (f *fakeModuleResolver) LookupVersions(_ context.Context, modPath string) (map[string][]cache.Meta, error) {
+	return f.versions[modPath], nil
+}
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		match            Match
+		version, adopted string
+		expected         bool
+	}{
+		{MatchExact, "v1.2.3", "v1.2.3", true},
+		{MatchExact, "v1.2.3", "v1.2.4", false},
+		{MatchMinor, "v1.2.3", "v1.2.0", true},
+		{MatchMinor, "v1.2.3", "v1.3.0", false},
+		{MatchMinor, "v0.0.0-20250101000000-abcdefabcdef", "v0.0.1", false},
+		{MatchMinor, "v0.0.2", "v0.0.2-0.20250101000000-abcdefabcdef", false},
+		{MatchMajor, "v0.0.0-20250101000000-abcdefabcdef", "v0.0.1", false},
+		{MatchMajor, "v1.2.4-0.20250101000000-abcdefabcdef", "v1.2.3", false},
+		{MatchPath, "v0.0.0-20250101000000-abcdefabcdef", "v0.0.1", true},
+		{MatchMajor, "v1.2.3", "v1.9.0", true},
+		{MatchMajor, "v1.2.3", "v0.9.0", false},
+		{MatchMajor, "v2.0.0+incompatible", "v2.1.0+incompatible", true},
+		{MatchMajor, "v0.4.1", "v0.4.0", true},
+		{MatchMajor, "v0.4.1", "v0.5.0", false},
+		{MatchPath, "v1.2.3", "v0.1.0", true},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.match.matches(tc.version, tc.adopted),
+			"%s: %s vs %s", tc.match, tc.version, tc.adopted)
+	}
+
+	_, err := ParseMatch("patch")
+	assert.ErrorContains(t, err, "invalid match level")
+
+	const goModSrc = `module example.com/foo
+
+go 1.25.0
+
+require (
+	example.com/bar v1.2.3 // indirect
+	example.com/baz v1.2.3 // indirect
+)
+`
+	goMod, err := modfile.Parse("go.mod", []byte(goModSrc), nil)
+	assert.NilError(t, err)
+	const goSumSrc = `example.com/bar v1.2.3 h1:bar123=
+example.com/baz v1.2.3 h1:baz123=
+`
+	goSum, err := parseGoSum(strings.NewReader(goSumSrc))
+	assert.NilError(t, err)
+	mi := &modInfo{
+		goModFilename: "/repo/go.mod",
+		goSumFilename: "/repo/go.sum",
+		goMod:         goMod,
+		goSum:         goSum,
+		policies:      map[string]string{},
+	}
+	resolver := &fakeModuleResolver{versions: map[string]map[string][]cache.Meta{
+		"example.com/bar": {"v1.2.0": {{Category: "cncf.io::graduated"}}},
+		"example.com/baz": {"v1.1.0": {{Category: "cncf.io::graduated"}}},
+	}}
+
+	for _, tc := range []struct {
+		match    Match
+		expected []string // modules reported
+	}{
+		{MatchExact, []string{"example.com/bar", "example.com/baz"}},
+		{MatchMinor, []string{"example.com/baz"}},
+		{MatchMajor, nil},
+	} {
+		t.Run(string(tc.match), func(t *testing.T) {
+			inst := newInstanceForTest(t, false, resolver)
+			inst.Opts.Match = tc.match
+			inst.recordModule(mi)
+			findings, err := inst.collectIndirect(context.TODO())
+			assert.NilError(t, err)
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.mod.Path)
+				assert.Equal(t, tc.match, f.match)
+				if tc.match != MatchExact {
+					assert.Assert(t, strings.Contains(f.msg, "match="+string(tc.match)), "msg: %q", f.msg)
+				}
+			}
+			assert.DeepEqual(t, tc.expected, got)
+		})
+	}
+
+	// The same version with another h1 sum is not adopted at any match level.
+	t.Run("mismatched sum", func(t *testing.T) {
+		resolver := &fakeModuleResolver{versions: map[string]map[string][]cache.Meta{
+			"example.com/bar": {"v1.2.3": {{Category: "cncf.io::graduated"}}},
+			"example.com/baz": {"v1.2.3": {{Category: "cncf.io::graduated"}}, "v1.2.0": {{Category: "cncf.io::graduated"}}},
+		}}
+		inst := newInstanceForTest(t, false, resolver)
+		inst.Opts.Match = MatchMinor
+		inst.recordModule(mi)
+		findings, err := inst.collectIndirect(context.TODO())
+		assert.NilError(t, err)
+		var got []string
+		for _, f := range findings {
+			got = append(got, f.mod.Path)
+		}
+		assert.DeepEqual(t, []string{"example.com/bar"}, got)
+	})
+
+	t.Run("resolver without version lookups", func(t *testing.T) {
+		inst := newInstanceForTest(t, false, &fakeResolver{})
+		inst.Opts.Match = MatchMinor
+		inst.recordModule(mi)
+		_, err := inst.collectIndirect(context.TODO())
+		assert.ErrorContains(t, err, "does not support --match=minor")
+	})
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for _, raw := range []string{
+		"bogus",
+		"untrusted,<=v1.0.0",
+		"trusted,until=tomorrow",
+		"trusted,<=1.0",
+	} {
+		_, err := parsePolicy(raw)
+		assert.Assert(t, err != nil, "expected an error for %q", raw)
+	}
+}
+
 func TestResolveReplace(t *testing.T) {
 	tests := []struct {
 		name     string
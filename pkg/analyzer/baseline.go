@@ -0,0 +1,154 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// baselineVersion is the version of the baseline file format.
+const baselineVersion = 1
+
+// BaselineEntry is a finding recorded in a [Baseline].
+type BaselineEntry struct {
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	// Sum is the h1 sum of the module, if known.
+	Sum string `json:"sum,omitempty"`
+}
+
+// Baseline is a set of known findings that are not reported again, so that the
+// analyzer can be adopted on an existing module and only fail on new findings.
+type Baseline struct {
+	Version int             `json:"version"`
+	Entries []BaselineEntry `json:"entries"`
+
+	mu   sync.Mutex
+	used map[BaselineEntry]bool
+}
+
+// ReadBaseline reads a baseline file written by [Analyzer.Flush] with
+// [Opts.WriteBaseline].
+func ReadBaseline(filename string) (*Baseline, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var bl Baseline
+	if err = json.Unmarshal(b, &bl); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %q: %w", filename, err)
+	}
+	if bl.Version != baselineVersion {
+		return nil, fmt.Errorf("baseline %q: unsupported version %d", filename, bl.Version)
+	}
+	return &bl, nil
+}
+
+func (bl *Baseline) write(filename string) error {
+	entries := bl.Entries
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Sum < b.Sum
+	})
+	bl.Entries = slices.Compact(entries)
+	b, err := json.MarshalIndent(bl, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if err = os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
+
+// match reports whether f is recorded in the baseline, and marks the entry as
+// used. An entry without a sum matches any sum.
+func (bl *Baseline) match(f finding) bool {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	if bl.used == nil {
+		bl.used = make(map[BaselineEntry]bool)
+	}
+	for _, e := range bl.Entries {
+		if e.Module != f.mod.Path || e.Version != f.mod.Version {
+			continue
+		}
+		if e.Sum != "" && e.Sum != f.sum {
+			continue
+		}
+		bl.used[e] = true
+		return true
+	}
+	return false
+}
+
+// stale returns the entries that did not match any finding.
+func (bl *Baseline) stale() []BaselineEntry {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	var res []BaselineEntry
+	for _, e := range bl.Entries {
+		if !bl.used[e] {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// baselined reports whether f must not be reported: either because it is being
+// recorded into a new baseline ([Opts.WriteBaseline]), or because it is already
+// recorded in [Opts.Baseline].
+func (inst *instance) baselined(ctx context.Context, f finding) bool {
+	if inst.Opts.WriteBaseline != "" {
+		inst.newBaselineMu.Lock()
+		inst.newBaseline = append(inst.newBaseline, BaselineEntry{
+			Module:  f.mod.Path,
+			Version: f.mod.Version,
+			Sum:     f.sum,
+		})
+		inst.newBaselineMu.Unlock()
+		return true
+	}
+	if inst.Opts.Baseline != nil && inst.Opts.Baseline.match(f) {
+		slog.DebugContext(ctx, "finding suppressed by the baseline", "path", f.mod.Path, "version", f.mod.Version)
+		return true
+	}
+	return false
+}
+
+// flushBaseline writes the new baseline when [Opts.WriteBaseline] is set, and
+// otherwise warns about the stale entries of [Opts.Baseline]: modules that are no
+// longer reported (e.g. upgraded, removed, or now adopted by a trusted project).
+func (inst *instance) flushBaseline(ctx context.Context) error {
+	if inst.Opts.WriteBaseline != "" {
+		inst.newBaselineMu.Lock()
+		bl := &Baseline{Version: baselineVersion, Entries: append([]BaselineEntry{}, inst.newBaseline...)}
+		inst.newBaselineMu.Unlock()
+		if err := bl.write(inst.Opts.WriteBaseline); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "wrote the baseline", "file", inst.Opts.WriteBaseline, "entries", len(bl.Entries))
+		return nil
+	}
+	if inst.Opts.Baseline == nil {
+		return nil
+	}
+	for _, e := range inst.Opts.Baseline.stale() {
+		slog.WarnContext(ctx, "stale baseline entry: the module is no longer reported; consider removing it from the baseline",
+			"module", e.Module, "version", e.Version, "sum", e.Sum)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/cache"
+)
+
+// Match selects how closely a trusted project's version of a module has to match
+// the checked version for the module to count as adopted.
+type Match string
+
+const (
+	// MatchExact requires the exact module version (by h1 sum).
+	MatchExact Match = "exact"
+	// MatchMinor accepts any version with the same major and minor version.
+	MatchMinor Match = "minor"
+	// MatchMajor accepts any version with the same major version.
+	// For v0, which makes no compatibility promise, it is the same as [MatchMinor].
+	MatchMajor Match = "major"
+	// MatchPath accepts any version of the same module path.
+	MatchPath Match = "path"
+)
+
+// ParseMatch validates s and returns the corresponding [Match].
+func ParseMatch(s string) (Match, error) {
+	m := Match(s)
+	switch m {
+	case MatchExact, MatchMinor, MatchMajor, MatchPath:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid match level %q (must be %q, %q, %q, or %q)",
+		s, MatchExact, MatchMinor, MatchMajor, MatchPath)
+}
+
+// ModuleResolver is an optional extension of [Resolver] used with a [Match]
+// level other than [MatchExact]. [*cache.Cache] implements it.
+type ModuleResolver interface {
+	// LookupVersions returns the versions of modPath whose h1 sum is recorded
+	// in the go.sum of trusted projects.
+	LookupVersions(ctx context.Context, modPath string) (map[string][]cache.Meta, error)
+}
+
+var _ ModuleResolver = (*cache.Cache)(nil)
+
+// matches reports whether the adopted version satisfies the match level for the
+// checked version. Pseudo-versions only match themselves at [MatchMinor] and
+// [MatchMajor], as an arbitrary commit says nothing about the compatibility with
+// the tagged releases.
+func (m Match) matches(version, adopted string) bool {
+	if m != MatchPath && (module.IsPseudoVersion(version) || module.IsPseudoVersion(adopted)) {
+		return version == adopted
+	}
+	switch m {
+	case MatchPath:
+		return true
+	case MatchMajor:
+		if semver.Major(version) != "v0" {
+			return semver.Major(version) != "" && semver.Major(version) == semver.Major(adopted)
+		}
+		fallthrough
+	case MatchMinor:
+		return semver.MajorMinor(version) != "" && semver.MajorMinor(version) == semver.MajorMinor(adopted)
+	default:
+		return version == adopted
+	}
+}
+
+func (inst *instance) match() Match {
+	if inst.Opts.Match == "" {
+		return MatchExact
+	}
+	return inst.Opts.Match
+}
+
+// lookupModule resolves modV (with h1 sum h1) to the trusted projects that have
+// adopted it, at the configured [Match] level.
+func (inst *instance) lookupModule(ctx context.Context, modV module.Version, h1 string) ([]cache.Meta, error) {
+	hit, err := inst.lookup(ctx, h1)
+	if err != nil || len(hit) > 0 {
+		return hit, err
+	}
+	m := inst.match()
+	if m == MatchExact {
+		return nil, nil
+	}
+	mr, ok := inst.Opts.Cache.(ModuleResolver)
+	if !ok {
+		return nil, fmt.Errorf("the resolver does not support --match=%s", m)
+	}
+	versions, err := mr.LookupVersions(ctx, modV.Path)
+	if err != nil {
+		return nil, err
+	}
+	adopted := make([]string, 0, len(versions))
+	for v := range versions {
+		// The exact version is only adopted with the same h1 sum, which the
+		// lookup above has already checked.
+		if v != modV.Version && m.matches(modV.Version, v) {
+			adopted = append(adopted, v)
+		}
+	}
+	// Prefer the closest (newest) matching versions.
+	sort.Slice(adopted, func(i, j int) bool { return semver.Compare(adopted[i], adopted[j]) > 0 })
+	for _, v := range adopted {
+		hit = append(hit, versions[v]...)
+	}
+	if len(hit) > 0 {
+		slog.DebugContext(ctx, "module adopted at a different version", "path", modV.Path, "version", modV.Version,
+			"match", m, "adoptedVersion", adopted[0])
+	}
+	return hit, nil
+}
@@ -0,0 +1,176 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+
+	"golang.org/x/mod/module"
+)
+
+// Format selects how findings are emitted.
+type Format string
+
+const (
+	// FormatText prints findings as "file:line:col: message" on stderr.
+	FormatText Format = "text"
+	// FormatJSON writes a JSON array of [Finding] to stdout.
+	FormatJSON Format = "json"
+	// FormatJSONL writes one [Finding] per line (JSON Lines) to stdout.
+	FormatJSONL Format = "jsonl"
+	// FormatSARIF writes a SARIF 2.1.0 log to stdout, for code-scanning
+	// integrations.
+	FormatSARIF Format = "sarif"
+)
+
+// ParseFormat validates s and returns the corresponding [Format].
+func ParseFormat(s string) (Format, error) {
+	f := Format(s)
+	switch f {
+	case FormatText, FormatJSON, FormatJSONL, FormatSARIF:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid format %q (must be %q, %q, %q, or %q)",
+		s, FormatText, FormatJSON, FormatJSONL, FormatSARIF)
+}
+
+// Position is a location in a file.
+type Position struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+}
+
+func newPosition(posn token.Position) *Position {
+	if posn.Filename == "" || posn.Line == 0 {
+		return nil
+	}
+	return &Position{
+		Filename: posn.Filename,
+		Line:     posn.Line,
+		Column:   posn.Column,
+	}
+}
+
+// Finding is the machine-readable form of a module that does not seem adopted by
+// a trusted project.
+type Finding struct {
+	// Module is the effective module path (after replace directives).
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	// Sum is the h1 sum of the module in go.sum.
+	Sum string `json:"sum,omitempty"`
+	// Kind is "direct" or "indirect".
+	Kind string `json:"kind"`
+	// Match is the [Match] level used to look up the module.
+	Match Match `json:"match"`
+	// Policy is the gosocialcheck directive policy that applied to the module.
+	Policy  string `json:"policy"`
+	Message string `json:"message"`
+	// GoMod is the require line in go.mod.
+	GoMod *Position `json:"go_mod,omitempty"`
+	// GoSum is the line in go.sum (or go.work.sum).
+	GoSum *Position `json:"go_sum,omitempty"`
+	// Imports lists the import sites of the module in the analyzed source.
+	Imports []Position `json:"imports,omitempty"`
+}
+
+func (inst *instance) structured() bool {
+	return inst.Opts.Format != "" && inst.Opts.Format != FormatText
+}
+
+func (inst *instance) addImportSite(mod module.Version, posn token.Position) {
+	inst.importSitesMu.Lock()
+	inst.importSites[mod] = append(inst.importSites[mod], posn)
+	inst.importSitesMu.Unlock()
+}
+
+// structuredFindings drains the buffered findings and returns them as sorted
+// [Finding]s with their import sites attached.
+func (inst *instance) structuredFindings() []Finding {
+	inst.findingsMu.Lock()
+	findings := inst.findings
+	inst.findings = nil
+	inst.findingsMu.Unlock()
+
+	inst.importSitesMu.Lock()
+	defer inst.importSitesMu.Unlock()
+	res := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		policy := f.policy
+		if policy == "" {
+			policy = directivePolicyUntrusted
+		}
+		if f.match == "" {
+			f.match = MatchExact
+		}
+		rec := Finding{
+			Module:  f.mod.Path,
+			Version: f.mod.Version,
+			Sum:     f.sum,
+			Kind:    f.kind,
+			Match:   f.match,
+			Policy:  policy,
+			Message: f.msg,
+			GoMod:   newPosition(f.modPosn),
+			GoSum:   newPosition(f.sumPosn),
+		}
+		posns := inst.importSites[f.mod]
+		sort.Slice(posns, func(i, j int) bool {
+			a, b := posns[i], posns[j]
+			if a.Filename != b.Filename {
+				return a.Filename < b.Filename
+			}
+			return a.Line < b.Line
+		})
+		for _, posn := range posns {
+			if p := newPosition(posn); p != nil {
+				rec.Imports = append(rec.Imports, *p)
+			}
+		}
+		res = append(res, rec)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		var aMod, bMod string
+		if a.GoMod != nil {
+			aMod = a.GoMod.Filename
+		}
+		if b.GoMod != nil {
+			bMod = b.GoMod.Filename
+		}
+		return aMod < bMod
+	})
+	return res
+}
+
+// flushStructured writes the buffered findings to w in the configured structured
+// [Format] and returns the number of findings written.
+func (inst *instance) flushStructured(w io.Writer) (int, error) {
+	findings := inst.structuredFindings()
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	switch inst.Opts.Format {
+	case FormatJSON:
+		enc.SetIndent("", "  ")
+		return len(findings), enc.Encode(findings)
+	case FormatJSONL:
+		for _, f := range findings {
+			if err := enc.Encode(f); err != nil {
+				return len(findings), err
+			}
+		}
+		return len(findings), nil
+	case FormatSARIF:
+		return len(findings), writeSARIF(w, inst.cwd, findings)
+	}
+	return 0, fmt.Errorf("unsupported structured format %q", inst.Opts.Format)
+}
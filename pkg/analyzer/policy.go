@@ -0,0 +1,199 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// directiveNamespace is the namespace of the go.mod directive comments, e.g.
+// "//gosocialcheck:trusted".
+const directiveNamespace = "gosocialcheck"
+
+// policyArgSep separates the policy name from its arguments in the normalized
+// form of a directive, e.g. "trusted,<=v1.4.0,until=2027-01-01".
+const policyArgSep = ","
+
+// untilLayout is the date layout of the until= argument.
+const untilLayout = time.DateOnly
+
+// normalizeDirectiveComments rewrites directive comments with arguments, such as
+//
+//	//gosocialcheck:trusted <=v1.4.0 until=2027-01-01
+//
+// into the single-token form "//gosocialcheck:trusted,<=v1.4.0,until=2027-01-01",
+// because gomoddirectivecomments only passes the first token of a directive
+// through as the policy.
+func normalizeDirectiveComments(goMod *modfile.File) {
+	normalizeComments(goMod.Syntax.Comment())
+	for _, stmt := range goMod.Syntax.Stmt {
+		normalizeComments(stmt.Comment())
+		if lb, ok := stmt.(*modfile.LineBlock); ok {
+			normalizeComments(&lb.RParen.Comments)
+			for _, l := range lb.Line {
+				normalizeComments(l.Comment())
+			}
+		}
+	}
+}
+
+func normalizeComments(c *modfile.Comments) {
+	for _, cs := range [][]modfile.Comment{c.Before, c.Suffix, c.After} {
+		for i := range cs {
+			cs[i].Token = normalizeDirectiveComment(cs[i].Token)
+		}
+	}
+}
+
+func normalizeDirectiveComment(tok string) string {
+	if !strings.Contains(tok, directiveNamespace+":") {
+		return tok
+	}
+	fields := strings.Fields(tok)
+	var res []string
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		res = append(res, f)
+		if !strings.HasPrefix(strings.TrimPrefix(f, "//"), directiveNamespace+":") {
+			continue
+		}
+		for i+1 < len(fields) && isPolicyArg(fields[i+1]) {
+			res[len(res)-1] += policyArgSep + fields[i+1]
+			i++
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+// isPolicyArg reports whether s looks like a directive argument: a version
+// constraint (e.g. "<=v1.4.0") or an "until=" date.
+func isPolicyArg(s string) bool {
+	if strings.HasPrefix(s, "until=") {
+		return true
+	}
+	_, v := splitConstraintOp(s)
+	return semver.IsValid(v)
+}
+
+var constraintOps = []string{"<=", ">=", "<", ">", "="}
+
+func splitConstraintOp(s string) (op, version string) {
+	for _, op := range constraintOps {
+		if strings.HasPrefix(s, op) {
+			return op, strings.TrimPrefix(s, op)
+		}
+	}
+	return "=", s
+}
+
+type versionConstraint struct {
+	op      string // one of constraintOps
+	version string
+}
+
+func (c versionConstraint) String() string {
+	return c.op + c.version
+}
+
+func (c versionConstraint) match(version string) bool {
+	cmp := semver.Compare(version, c.version)
+	switch c.op {
+	case "<=":
+		return cmp <= 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp == 0
+	}
+}
+
+// policy is a parsed directive policy, e.g. "trusted" with optional version
+// constraints and an expiry date.
+type policy struct {
+	name        string
+	constraints []versionConstraint
+	until       time.Time
+}
+
+// parsePolicy parses the normalized form of a directive policy. The empty string
+// is the default (untrusted) policy.
+func parsePolicy(raw string) (*policy, error) {
+	if raw == "" {
+		return &policy{name: directivePolicyUntrusted}, nil
+	}
+	parts := strings.Split(raw, policyArgSep)
+	p := &policy{name: parts[0]}
+	switch p.name {
+	case directivePolicyTrusted, directivePolicyUntrusted:
+	default:
+		return nil, fmt.Errorf("unknown policy %q", p.name)
+	}
+	args := parts[1:]
+	if len(args) > 0 && p.name != directivePolicyTrusted {
+		return nil, fmt.Errorf("policy %q does not take arguments, got %v", p.name, args)
+	}
+	for _, arg := range args {
+		if s, ok := strings.CutPrefix(arg, "until="); ok {
+			t, err := time.Parse(untilLayout, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid until= date %q (expected YYYY-MM-DD): %w", s, err)
+			}
+			p.until = t
+			continue
+		}
+		op, v := splitConstraintOp(arg)
+		if !semver.IsValid(v) {
+			return nil, fmt.Errorf("invalid version constraint %q", arg)
+		}
+		p.constraints = append(p.constraints, versionConstraint{op: op, version: v})
+	}
+	return p, nil
+}
+
+// String returns the policy as written in go.mod, e.g. "trusted <=v1.4.0".
+func (p *policy) String() string {
+	ss := []string{p.name}
+	for _, c := range p.constraints {
+		ss = append(ss, c.String())
+	}
+	if !p.until.IsZero() {
+		ss = append(ss, "until="+p.until.Format(untilLayout))
+	}
+	return strings.Join(ss, " ")
+}
+
+// check reports whether the policy trusts the module at version as of now.
+// When a trusted policy does not apply, the returned note explains why (e.g. the
+// module was upgraded out of the reviewed range, or the trust expired).
+func (p *policy) check(version string, now time.Time) (bool, string) {
+	if p.name != directivePolicyTrusted {
+		return false, ""
+	}
+	for _, c := range p.constraints {
+		if !c.match(version) {
+			return false, fmt.Sprintf("%s:%s does not cover %s", directiveNamespace, p, version)
+		}
+	}
+	if !p.until.IsZero() && !now.Before(p.until) {
+		return false, fmt.Sprintf("%s:%s has expired", directiveNamespace, p)
+	}
+	return true, ""
+}
+
+// validatePolicies checks that every policy parsed from a go.mod is valid, so
+// that mistakes in directives are reported up front.
+func validatePolicies(policies map[string]string) error {
+	for modPath, raw := range policies {
+		if _, err := parsePolicy(raw); err != nil {
+			return fmt.Errorf("module %q: %w", modPath, err)
+		}
+	}
+	return nil
+}
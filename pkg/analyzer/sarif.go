@@ -0,0 +1,230 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// SARIF 2.1.0 log, reduced to the properties emitted by gosocialcheck.
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// sarifFingerprintKey names the partial fingerprint. Bump the suffix if the
+	// fingerprint computation changes.
+	sarifFingerprintKey = "gosocialcheck/v1"
+)
+
+// SARIF rule IDs. These are stable so that code-scanning UIs can track alerts
+// across runs.
+const (
+	sarifRuleUntrustedDirect   = "untrusted-direct"
+	sarifRuleUntrustedIndirect = "untrusted-indirect"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string           `json:"id"`
+	Name                 string           `json:"name"`
+	ShortDescription     sarifMessage     `json:"shortDescription"`
+	FullDescription      sarifMessage     `json:"fullDescription"`
+	HelpURI              string           `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifRuleConfigs `json:"defaultConfiguration"`
+}
+
+type sarifRuleConfigs struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	RelatedLocations    []sarifLocation   `json:"relatedLocations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	Properties          map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+var sarifRules = []sarifRule{
+	{
+		ID:               sarifRuleUntrustedDirect,
+		Name:             "UntrustedDirectDependency",
+		ShortDescription: sarifMessage{Text: "Direct dependency not adopted by a trusted project"},
+		FullDescription: sarifMessage{Text: "The module is imported by the analyzed source but does not seem " +
+			"adopted by a trusted project. Negligible if you trust the module; " +
+			"mark it with a //gosocialcheck:trusted directive in go.mod to silence the alert."},
+		HelpURI:              "https://github.com/AkihiroSuda/gosocialcheck#allowlist",
+		DefaultConfiguration: sarifRuleConfigs{Level: "warning"},
+	},
+	{
+		ID:               sarifRuleUntrustedIndirect,
+		Name:             "UntrustedIndirectDependency",
+		ShortDescription: sarifMessage{Text: "Indirect dependency not adopted by a trusted project"},
+		FullDescription: sarifMessage{Text: "The module is required (indirectly) in go.mod but does not seem " +
+			"adopted by a trusted project. Negligible if you trust the module; " +
+			"mark it with a //gosocialcheck:trusted directive in go.mod to silence the alert."},
+		HelpURI:              "https://github.com/AkihiroSuda/gosocialcheck#allowlist",
+		DefaultConfiguration: sarifRuleConfigs{Level: "warning"},
+	},
+}
+
+// sarifLocationOf converts p into a SARIF location. Files under cwd are
+// referenced relative to %SRCROOT% so that code-scanning UIs can map them to the
+// repository; other files are referenced by absolute file URI.
+func sarifLocationOf(cwd string, p Position, msg string) sarifLocation {
+	loc := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactURI(cwd, p.Filename),
+			Region: sarifRegion{
+				StartLine:   p.Line,
+				StartColumn: p.Column,
+			},
+		},
+	}
+	if msg != "" {
+		loc.Message = &sarifMessage{Text: msg}
+	}
+	return loc
+}
+
+func sarifArtifactURI(cwd, filename string) sarifArtifactLocation {
+	if rel, err := filepath.Rel(cwd, filename); err == nil && !strings.HasPrefix(rel, "..") && !filepath.IsAbs(rel) {
+		return sarifArtifactLocation{
+			URI:       filepath.ToSlash(rel),
+			URIBaseID: "%SRCROOT%",
+		}
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(filename)}
+	return sarifArtifactLocation{URI: u.String()}
+}
+
+// sarifFingerprint identifies a finding independently of line numbers, so that
+// alerts survive unrelated edits to go.mod and go.sum.
+func sarifFingerprint(cwd, ruleID string, f Finding) string {
+	var goMod string
+	if f.GoMod != nil {
+		goMod = sarifArtifactURI(cwd, f.GoMod.Filename).URI
+	}
+	h := sha256.Sum256([]byte(strings.Join([]string{ruleID, goMod, f.Module, f.Version}, "\x00")))
+	return hex.EncodeToString(h[:])
+}
+
+func newSARIFLog(cwd string, findings []Finding) *sarifLog {
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		ruleID, ruleIndex := sarifRuleUntrustedDirect, 0
+		if f.Kind == findingKindIndirect {
+			ruleID, ruleIndex = sarifRuleUntrustedIndirect, 1
+		}
+		res := sarifResult{
+			RuleID:    ruleID,
+			RuleIndex: ruleIndex,
+			Level:     "warning",
+			Message:   sarifMessage{Text: f.Message},
+			PartialFingerprints: map[string]string{
+				sarifFingerprintKey: sarifFingerprint(cwd, ruleID, f),
+			},
+			Properties: map[string]string{
+				"module":  f.Module,
+				"version": f.Version,
+				"sum":     f.Sum,
+				"policy":  f.Policy,
+				"match":   string(f.Match),
+			},
+		}
+		// The primary location is the go.mod require line (or the go.sum line
+		// when unavailable); the go.sum line and import sites are related.
+		switch {
+		case f.GoMod != nil:
+			res.Locations = append(res.Locations, sarifLocationOf(cwd, *f.GoMod, ""))
+			if f.GoSum != nil {
+				res.RelatedLocations = append(res.RelatedLocations, sarifLocationOf(cwd, *f.GoSum, "go.sum entry"))
+			}
+		case f.GoSum != nil:
+			res.Locations = append(res.Locations, sarifLocationOf(cwd, *f.GoSum, ""))
+		}
+		for _, imp := range f.Imports {
+			res.RelatedLocations = append(res.RelatedLocations, sarifLocationOf(cwd, imp, "import site"))
+		}
+		for i := range res.RelatedLocations {
+			res.RelatedLocations[i].ID = i + 1
+		}
+		results = append(results, res)
+	}
+	return &sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "gosocialcheck",
+						InformationURI: "https://github.com/AkihiroSuda/gosocialcheck",
+						Rules:          sarifRules,
+					},
+				},
+				Results: results,
+			},
+		},
+	}
+}
+
+func writeSARIF(w io.Writer, cwd string, findings []Finding) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newSARIFLog(cwd, findings))
+}
@@ -0,0 +1,246 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	gomoddirectivecomments "github.com/AkihiroSuda/gomoddirectivecomments"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// workspace holds the parsed state of a go.work workspace.
+// See https://go.dev/ref/mod#workspaces
+type workspace struct {
+	goWorkFilename string
+	goWork         *modfile.WorkFile
+	// members maps the module path of each `use`d module to its go.mod filename.
+	members map[string]string
+	// buildList maps a module path to the version selected by minimal version
+	// selection (MVS) across the members, i.e., the maximum required version.
+	buildList map[string]string
+	// goSum merges go.work.sum and the go.sum of every member module, as the go
+	// command consults all of them when verifying a module in workspace mode.
+	goSum map[string]goSumEntry
+}
+
+// loadModule returns the parsed state of the module whose go.mod is
+// goModFilename, recording it for Flush. When the module belongs to a go.work
+// workspace, every member module of the workspace is recorded as well.
+func (inst *instance) loadModule(goModFilename string) (*modInfo, error) {
+	inst.modsMu.Lock()
+	mi, ok := inst.mods[goModFilename]
+	inst.modsMu.Unlock()
+	if ok {
+		return mi, nil
+	}
+	work, err := inst.loadWorkspace(filepath.Dir(goModFilename))
+	if err != nil {
+		return nil, err
+	}
+	if work != nil {
+		inst.modsMu.Lock()
+		mi, ok = inst.mods[goModFilename]
+		inst.modsMu.Unlock()
+		if ok {
+			return mi, nil
+		}
+		// Not a member of the workspace (e.g. the module is excluded from `use`).
+	}
+	mi, err = readModule(goModFilename, false)
+	if err != nil {
+		return nil, err
+	}
+	inst.recordModule(mi)
+	return mi, nil
+}
+
+// readModule reads go.mod and the sibling go.sum. A missing go.sum is only
+// tolerated for workspace members, whose sums may live in go.work.sum.
+func readModule(goModFilename string, inWorkspace bool) (*modInfo, error) {
+	// pass.ReadFile does not support go.mod
+	goModB, err := os.ReadFile(goModFilename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", goModFilename, err)
+	}
+	goMod, err := modfile.Parse(goModFilename, goModB, nil)
+	if err != nil {
+		return nil, err
+	}
+	if goMod.Module == nil {
+		return nil, fmt.Errorf("%s: missing module directive", goModFilename)
+	}
+	normalizeDirectiveComments(goMod)
+	policies, err := gomoddirectivecomments.Parse(goMod, directiveNamespace, directivePolicyUntrusted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse gosocialcheck directives in %q: %w", goModFilename, err)
+	}
+	if err = validatePolicies(policies); err != nil {
+		return nil, fmt.Errorf("invalid gosocialcheck directive in %q: %w", goModFilename, err)
+	}
+	goSumFilename := filepath.Join(filepath.Dir(goModFilename), "go.sum")
+	goSum, err := readGoSum(goSumFilename)
+	if err != nil {
+		if !inWorkspace || !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %q: %w", goSumFilename, err)
+		}
+		goSum = make(map[string]goSumEntry)
+	}
+	return &modInfo{
+		goModFilename: goModFilename,
+		goSumFilename: goSumFilename,
+		goMod:         goMod,
+		goSum:         goSum,
+		policies:      policies,
+	}, nil
+}
+
+// loadWorkspace returns the go.work workspace that applies to a module in dir,
+// or nil when workspace mode is not active. The workspace is parsed once and its
+// member modules are recorded for Flush.
+func (inst *instance) loadWorkspace(dir string) (*workspace, error) {
+	goWorkFilename, err := findGoWork(dir)
+	if err != nil || goWorkFilename == "" {
+		return nil, err
+	}
+	inst.worksMu.Lock()
+	defer inst.worksMu.Unlock()
+	if work, ok := inst.works[goWorkFilename]; ok {
+		return work, nil
+	}
+	goWorkB, err := os.ReadFile(goWorkFilename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", goWorkFilename, err)
+	}
+	goWork, err := modfile.ParseWork(goWorkFilename, goWorkB, nil)
+	if err != nil {
+		return nil, err
+	}
+	work := &workspace{
+		goWorkFilename: goWorkFilename,
+		goWork:         goWork,
+		members:        make(map[string]string),
+		buildList:      make(map[string]string),
+		goSum:          make(map[string]goSumEntry),
+	}
+	workDir := filepath.Dir(goWorkFilename)
+	var mods []*modInfo
+	for _, use := range goWork.Use {
+		useDir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(useDir) {
+			useDir = filepath.Join(workDir, useDir)
+		}
+		mi, err := readModule(filepath.Join(useDir, "go.mod"), true)
+		if err != nil {
+			return nil, err
+		}
+		mi.work = work
+		work.members[mi.goMod.Module.Mod.Path] = mi.goModFilename
+		mods = append(mods, mi)
+	}
+	// The member go.mod files list every module of their (pruned) module graphs
+	// since Go 1.17, so the maximum of their requirements is the build list.
+	for _, mi := range mods {
+		for _, r := range mi.goMod.Require {
+			if v, ok := work.buildList[r.Mod.Path]; !ok || semver.Compare(r.Mod.Version, v) > 0 {
+				work.buildList[r.Mod.Path] = r.Mod.Version
+			}
+		}
+	}
+	// Member go.sum files take precedence over go.work.sum, in a deterministic
+	// order, so that findings point at the file the entry most likely came from.
+	sort.Slice(mods, func(i, j int) bool { return mods[i].goModFilename < mods[j].goModFilename })
+	for _, mi := range mods {
+		mergeGoSum(work.goSum, mi.goSum)
+	}
+	goWorkSum, err := readGoSum(goWorkFilename + ".sum")
+	switch {
+	case err == nil:
+		mergeGoSum(work.goSum, goWorkSum)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	for _, mi := range mods {
+		inst.recordModule(mi)
+	}
+	inst.works[goWorkFilename] = work
+	return work, nil
+}
+
+func mergeGoSum(dst, src map[string]goSumEntry) {
+	for k, e := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = e
+		}
+	}
+}
+
+// findGoWork returns the go.work file that applies to dir, following the same
+// rules as the go command: $GOWORK=off disables workspace mode, a non-empty
+// $GOWORK names the file, and otherwise the nearest go.work in dir or one of its
+// parents is used. It returns "" when workspace mode is not active.
+func findGoWork(dir string) (string, error) {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return "", nil
+	case "":
+	default:
+		return filepath.Abs(gowork)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		f := filepath.Join(dir, "go.work")
+		if st, err := os.Stat(f); err == nil && !st.IsDir() {
+			return f, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// resolve applies replace directives to reqMod. In a go.work workspace, the
+// version is first raised to the one the go command builds (see
+// workspace.buildList), replace directives in go.work override those in the
+// member's go.mod, and workspace members are resolved to the local copy (nil).
+func (mi *modInfo) resolve(reqMod module.Version) *module.Version {
+	if mi.work != nil {
+		if _, ok := mi.work.members[reqMod.Path]; ok {
+			return nil
+		}
+		if v, ok := mi.work.buildList[reqMod.Path]; ok && semver.Compare(v, reqMod.Version) > 0 {
+			reqMod.Version = v
+		}
+		if modV, ok := applyReplace(mi.work.goWork.Replace, reqMod); ok {
+			return modV
+		}
+	}
+	return resolveReplace(mi.goMod, reqMod)
+}
+
+// lookupSum returns the go.sum entry for modV, consulting the module's own
+// go.sum first and then, in workspace mode, the other members' go.sum files and
+// go.work.sum. The returned entry always has Filename set when found.
+func (mi *modInfo) lookupSum(modV module.Version) goSumEntry {
+	key := modV.Path + " " + modV.Version
+	if e, ok := mi.goSum[key]; ok {
+		if e.Filename == "" {
+			e.Filename = mi.goSumFilename
+		}
+		return e
+	}
+	if mi.work != nil {
+		return mi.work.goSum[key]
+	}
+	return goSumEntry{}
+}
@@ -1,48 +1,60 @@
 // Package cache manages the cache.
 
 /*
-TODO: consider switching to bbolt
-
 ~/.cache: the cache home ($XDG_CACHE_HOME)
   gosocialcheck
-    _local: rebuilt from upstream sources (CNCF + GitHub APIs)
-      github.com
-        containerd
+    _local: rebuilt from the trust sources (pkg/source) and the forge APIs (pkg/netutil/forge) or the module proxies (pkg/netutil/goproxy)
+      github.com (the forge host)
+        containerd (the owner; may contain slashes for GitLab subgroups)
           containerd
            fb4c30d4ede3531652d86197bf3fc9515e5276d9
              gosocialcheck-meta.json
              go.mod
              go.sum
+           branch-main (the tips of a branch, pruned on updates)
+             9f8e7d6c5b4a39281706f5e4d3c2b1a098765432
+               gosocialcheck-meta.json
+               go.mod
+               go.sum
     _remote: shallow clone of the preprocessed cache repository
+    _index
+      local.json: go.sum hash -> snapshot index of _local, rebuilt by update
+      remote.json: same as above, for _remote
+    _state
+      local.json: the validators and the digests of the repositories for the incremental update of _local
 */
 
 package cache
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/mod/semver"
 	"golang.org/x/sync/errgroup"
-	"gopkg.in/yaml.v3"
 
 	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
 	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
-	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/github"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+	_ "github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge/builtin"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/goproxy"
 	"github.com/AkihiroSuda/gosocialcheck/pkg/progress"
-	"github.com/AkihiroSuda/gosocialcheck/pkg/source/cncf"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
 )
 
 // Mode selects which cache flavor to use.
@@ -83,6 +95,19 @@ type opts struct {
 	remoteURL  string
 	onProgress progress.Handler
 	httpClient *http.Client
+	categories []string
+	sources    []source.Source
+	sourceRefs map[string]source.Refs
+	// defaultBranch forces [source.Refs.DefaultBranch] for every repository.
+	defaultBranch bool
+	// discoverModules forces [source.Repository.DiscoverModules] for every repository.
+	discoverModules bool
+	// keepGoing continues [Cache.Update] after the failures of sources and repositories.
+	keepGoing bool
+	fetcher   Fetcher
+	goProxy   *goproxy.Config
+	// maxRetryWait is passed to [netutil.WithMaxRetryWait] unless zero.
+	maxRetryWait time.Duration
 }
 
 type Opt func(*opts) error
@@ -131,6 +156,85 @@ func WithHTTPClient(httpClient *http.Client) Opt {
 	}
 }
 
+// WithCategories sets the trusted categories (e.g. [categories.CNCFGraduated]).
+// Lookups ignore snapshots of the other categories, and [ModeLocal] updates only
+// fetch the projects of these categories. Defaults to [categories.Default].
+func WithCategories(cats ...string) Opt {
+	return func(opts *opts) error {
+		if len(cats) == 0 {
+			return nil
+		}
+		opts.categories = cats
+		return nil
+	}
+}
+
+// WithSources adds trust sources (e.g. user-defined ones) to the built-in
+// sources registered with [source.Register]. [ModeLocal] updates index the
+// repositories of the trusted categories ([WithCategories]) of every source.
+func WithSources(sources ...source.Source) Opt {
+	return func(opts *opts) error {
+		opts.sources = append(opts.sources, sources...)
+		return nil
+	}
+}
+
+// WithSourceRefs overrides the ref selection (e.g. the number of the tags) of the
+// repositories of the sources, keyed by [source.Source.Name]. Only the non-zero
+// fields of each [source.Refs] override the defaults of the source.
+func WithSourceRefs(sourceRefs map[string]source.Refs) Opt {
+	return func(opts *opts) error {
+		for name, refs := range sourceRefs {
+			if err := refs.Validate(); err != nil {
+				return fmt.Errorf("source %q: %w", name, err)
+			}
+		}
+		opts.sourceRefs = sourceRefs
+		return nil
+	}
+}
+
+// WithDefaultBranch also indexes the tip of the default branch of every
+// repository, regardless of [source.Refs.DefaultBranch].
+func WithDefaultBranch(defaultBranch bool) Opt {
+	return func(opts *opts) error {
+		opts.defaultBranch = defaultBranch
+		return nil
+	}
+}
+
+// WithDiscoverModules also indexes the nested modules of every repository,
+// regardless of [source.Repository.DiscoverModules].
+func WithDiscoverModules(discoverModules bool) Opt {
+	return func(opts *opts) error {
+		opts.discoverModules = discoverModules
+		return nil
+	}
+}
+
+// WithKeepGoing continues the local update after the failures of sources and
+// repositories, and updates the cache with the rest.
+// The failures are returned together as an error, wrapping [UpdateError]s.
+func WithKeepGoing(keepGoing bool) Opt {
+	return func(opts *opts) error {
+		opts.keepGoing = keepGoing
+		return nil
+	}
+}
+
+// WithMaxRetryWait sets the maximum wait before retrying an HTTP request,
+// e.g. [time.Hour] to wait for the reset of the primary rate limit of the
+// GitHub API. Zero means [netutil.DefaultMaxRetryWait].
+func WithMaxRetryWait(d time.Duration) Opt {
+	return func(opts *opts) error {
+		if d < 0 {
+			return fmt.Errorf("invalid max retry wait %s", d)
+		}
+		opts.maxRetryWait = d
+		return nil
+	}
+}
+
 // New instantiates [Cache].
 func New(o ...Opt) (*Cache, error) {
 	var c Cache
@@ -158,18 +262,61 @@ func New(o ...Opt) (*Cache, error) {
 	if c.opts.httpClient == nil {
 		c.opts.httpClient = http.DefaultClient
 	}
+	if len(c.opts.categories) == 0 {
+		c.opts.categories = categories.Default
+	}
+	if c.opts.fetcher == "" {
+		c.opts.fetcher = FetcherForge
+	}
+	if c.opts.fetcher == FetcherGoProxy && c.opts.goProxy == nil {
+		var err error
+		if c.opts.goProxy, err = goproxy.FromEnv(); err != nil {
+			return nil, err
+		}
+	}
 	return &c, nil
 }
 
 type Cache struct {
 	opts
+
+	// idx caches the loaded index, keyed by the cache flavor directory.
+	idxMu sync.Mutex
+	idx   map[string]*index
+
+	// state is non-nil during [Cache.updateLocal].
+	state *state
 }
 
 func (c *Cache) httpOpts() []netutil.HTTPOpt {
-	return []netutil.HTTPOpt{
+	return append(c.goProxyHTTPOpts(), netutil.WithAutoToken())
+}
+
+// goProxyHTTPOpts is httpOpts without the tokens of the forges, for the module
+// proxies, which authenticate with the netrc file instead.
+func (c *Cache) goProxyHTTPOpts() []netutil.HTTPOpt {
+	o := []netutil.HTTPOpt{
 		netutil.WithHTTPClient(c.httpClient),
-		netutil.WithAutoGitHubToken(),
+		netutil.WithProgressEventHandler(c.onProgress),
 	}
+	if c.opts.maxRetryWait != 0 {
+		o = append(o, netutil.WithMaxRetryWait(c.opts.maxRetryWait))
+	}
+	return o
+}
+
+// conditionalHTTPOpts extends httpOpts with the validators of the previous
+// update, for the listings that are fetched on every update.
+func (c *Cache) conditionalHTTPOpts() []netutil.HTTPOpt {
+	return c.withValidators(c.httpOpts())
+}
+
+// withValidators appends the validators of the previous update to o.
+func (c *Cache) withValidators(o []netutil.HTTPOpt) []netutil.HTTPOpt {
+	if c.state != nil {
+		o = append(o, netutil.WithValidatorStore(c.state))
+	}
+	return o
 }
 
 // LocalDir is the directory of the locally rebuilt cache.
@@ -256,15 +403,18 @@ type RemoteStatus struct {
 // Status reports the cache status.
 type Status struct {
 	// Mode is the configured cache mode (auto/remote/local).
-	Mode   Mode         `json:"mode"`
-	Local  SubStatus    `json:"local"`
-	Remote RemoteStatus `json:"remote"`
+	Mode Mode `json:"mode"`
+	// Categories are the trusted categories.
+	Categories []string     `json:"categories"`
+	Local      SubStatus    `json:"local"`
+	Remote     RemoteStatus `json:"remote"`
 }
 
 // Status returns the current cache status.
 func (c *Cache) Status() *Status {
 	s := &Status{
-		Mode: c.opts.mode,
+		Mode:       c.opts.mode,
+		Categories: c.opts.categories,
 		Local: SubStatus{
 			Dir: c.LocalDir(),
 		},
@@ -303,31 +453,138 @@ func (c *Cache) Update(ctx context.Context) error {
 	return fmt.Errorf("unsupported cache mode for update: %q", mode)
 }
 
-func (c *Cache) updateLocal(ctx context.Context) error {
+func (c *Cache) updateLocal(ctx context.Context) (retErr error) {
 	dir := c.LocalDir()
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	// The ModTime of dir is the time of the last successful update, which
+	// ReadMode compares with the remote cache. An update that fails restores
+	// it, as writing the snapshots modifies dir; a new dir gets the zero Unix
+	// time, so that it is older than the remote cache.
+	lastUpdated, err := modTime(dir)
+	if err != nil {
+		lastUpdated = time.Unix(0, 0)
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
 		return err
 	}
-	b, err := netutil.Get(ctx, cncf.ProjectsURL, c.httpOpts()...)
+	var updated bool
+	defer func() {
+		if !updated {
+			if err := os.Chtimes(dir, lastUpdated, lastUpdated); err != nil {
+				retErr = errors.Join(retErr, err)
+			}
+		}
+	}()
+	stateFile := c.stateFile()
+	st, err := readState(stateFile)
 	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", stateFile, err)
+	}
+	c.state = st
+	defer func() {
+		c.state = nil
+		// The state is saved on failures too, so that the next update resumes
+		// from the repositories that were completed.
+		if err := writeState(stateFile, st); err != nil {
+			retErr = errors.Join(retErr, err)
+		}
+	}()
+	if err := os.RemoveAll(filepath.Join(dir, stagingDirName)); err != nil {
 		return err
 	}
-	var projects cncf.Projects
-	if err = yaml.Unmarshal(b, &projects); err != nil {
+	// The index is rebuilt at the end of the update. Until then, it is
+	// invalidated so that the snapshots stored by an update that fails midway
+	// are indexed by the next lookup.
+	if err := c.invalidateIndex(dir); err != nil {
 		return err
 	}
-	for _, p := range projects {
-		if err = c.updateCNCFProject(ctx, p); err != nil {
-			return err
+	sourceOpts := source.Opts{
+		Categories: c.opts.categories,
+		HTTPOpts:   c.conditionalHTTPOpts(),
+	}
+	var (
+		srcErrs, repoErrs []error
+		failedSources     []string
+		total             int
+	)
+	for _, src := range append(source.Registered(), c.opts.sources...) {
+		st.setSource(src.Name())
+		repos, err := src.Repositories(ctx, sourceOpts)
+		if err != nil {
+			err = &UpdateError{Source: src.Name(), Err: err}
+			if !c.opts.keepGoing {
+				return err
+			}
+			c.onProgress(ctx, progress.Event{Message: err.Error()})
+			srcErrs = append(srcErrs, err)
+			failedSources = append(failedSources, src.Name())
+			continue
+		}
+		for _, r := range repos {
+			if !slices.Contains(c.opts.categories, r.Category) {
+				continue
+			}
+			if o, ok := c.opts.sourceRefs[src.Name()]; ok {
+				r.Refs = r.Refs.Override(o)
+			}
+			total++
+			if err = c.updateRepo(ctx, r); err != nil {
+				err = &UpdateError{Source: src.Name(), URL: r.URL, Err: err}
+				if !c.opts.keepGoing || ctx.Err() != nil {
+					return err
+				}
+				c.onProgress(ctx, progress.Event{Message: err.Error()})
+				repoErrs = append(repoErrs, err)
+			}
 		}
 	}
+	// The validators are pruned only when every source has been visited, except
+	// the validators of the sources that failed to list their repositories.
+	st.pruneValidators(failedSources)
+	errs := append(srcErrs, repoErrs...)
+	// With keepGoing, the cache is reindexed with the repositories that were
+	// updated successfully.
+	if err := c.reindex(ctx, dir); err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	if err := os.Remove(filepath.Join(dir, legacyStateFilename)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Join(append(errs, err)...)
+	}
 	now := time.Now()
-	if err = os.Chtimes(dir, now, now); err != nil {
-		return err
+	if err := os.Chtimes(dir, now, now); err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	updated = true
+	if len(errs) > 0 {
+		var summary []string
+		if len(srcErrs) > 0 {
+			summary = append(summary, fmt.Sprintf("failed to list the repositories of %d source(s)", len(srcErrs)))
+		}
+		if len(repoErrs) > 0 {
+			summary = append(summary, fmt.Sprintf("failed to update %d of %d repositories", len(repoErrs), total))
+		}
+		return fmt.Errorf("%s:\n%w", strings.Join(summary, ", "), errors.Join(errs...))
 	}
 	return nil
 }
 
+// UpdateError is an error of updating a source, or a repository of a source.
+type UpdateError struct {
+	Source string
+	URL    string // empty for the errors of listing the repositories
+	Err    error
+}
+
+func (e *UpdateError) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("source %q: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("source %q: %s: %v", e.Source, e.URL, e.Err)
+}
+
+func (e *UpdateError) Unwrap() error {
+	return e.Err
+}
+
 func (c *Cache) updateRemote(ctx context.Context) error {
 	dir := c.RemoteDir()
 	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
@@ -353,6 +610,9 @@ func (c *Cache) updateRemote(ctx context.Context) error {
 			return fmt.Errorf("git reset failed: %w: %s", err, out)
 		}
 	}
+	if err := c.reindex(ctx, dir); err != nil {
+		return err
+	}
 	now := time.Now()
 	if err := os.Chtimes(dir, now, now); err != nil {
 		return err
@@ -370,54 +630,110 @@ func runGit(ctx context.Context, dir string, args ...string) (string, error) {
 	return strings.TrimSpace(string(out)), err
 }
 
-func (c *Cache) updateCNCFProject(ctx context.Context, p cncf.Project) error {
-	if p.Maturity != "graduated" {
-		return nil
-	}
-	for _, r := range p.Repositories {
-		if err := c.updateCNCFRepo(ctx, r); err != nil {
-			return err
-		}
-	}
-	return nil
+// Categories returns the trusted categories.
+func (c *Cache) Categories() []string {
+	return c.opts.categories
 }
 
-func (c *Cache) updateCNCFRepo(ctx context.Context, r cncf.Repository) error {
-	var category string
-	// TODO: include repos that belong to the same org as "code".
-	//       Most of them should be accidentally ommited out from "code-lite".
-	switch {
-	case slices.Contains(r.CheckSets, "code"):
-		category = categories.CNCFGraduated
-	case slices.Contains(r.CheckSets, "code-lite"):
-		// TODO: opt-in
-		//	category = categories.CNCFGraduatedSub
-	}
-	if category != "" {
-		if err := c.updateGitHubRepo(ctx, r.URL, category); err != nil {
-			return err
-		}
-	}
-	return nil
+// trusts reports whether m belongs to a trusted category.
+func (c *Cache) trusts(m Meta) bool {
+	return slices.Contains(c.opts.categories, m.Category)
 }
 
-func filterPrelease(tags []github.Tag) []github.Tag {
-	var res []github.Tag
+func filterPrelease(tags []forge.Tag) []forge.Tag {
+	var res []forge.Tag
 	for _, tag := range tags {
-		if semver.Prerelease(tag.Name) == "" {
+		if semver.Prerelease(tagVersion(tag.Name)) == "" {
 			res = append(res, tag)
 		}
 	}
 	return res
 }
 
+// tagVersion returns the semver of a tag name, e.g. "v1.24.3" for "v1.24.3"
+// and "go1.24.3", or the empty string if the tag is not a version.
+func tagVersion(name string) string {
+	if semver.IsValid(name) {
+		return name
+	}
+	if v, ok := strings.CutPrefix(name, "go"); ok && semver.IsValid("v"+v) {
+		return "v" + v
+	}
+	return ""
+}
+
+// sortTagsByVersion sorts the tags newest first. Tags that are not versions
+// are moved to the end, in the original order.
+func sortTagsByVersion(tags []forge.Tag) {
+	slices.SortStableFunc(tags, func(a, b forge.Tag) int {
+		av, bv := tagVersion(a.Name), tagVersion(b.Name)
+		switch {
+		case av == "" && bv == "":
+			return 0
+		case av == "":
+			return 1
+		case bv == "":
+			return -1
+		}
+		return semver.Compare(bv, av)
+	})
+}
+
+// selectTags selects the release tags to index, newest first, as configured
+// by refs.
+func selectTags(tags []forge.Tag, refs source.Refs) ([]forge.Tag, error) {
+	if refs.TagPattern != "" {
+		tagRE, err := regexp.Compile(refs.TagPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag pattern: %w", err)
+		}
+		tags = slices.DeleteFunc(slices.Clone(tags), func(t forge.Tag) bool { return !tagRE.MatchString(t.Name) })
+	}
+	tags = filterPrelease(tags)
+	tags = dedupTagsBySHA(tags)
+	sortTagsByVersion(tags)
+	if refs.PatchesPerMinor > 0 {
+		var (
+			res     []forge.Tag
+			minors  []string
+			patches = make(map[string]int)
+		)
+		for _, t := range tags {
+			v := tagVersion(t.Name)
+			if v == "" {
+				continue
+			}
+			mm := semver.MajorMinor(v)
+			if !slices.Contains(minors, mm) {
+				if refs.MinorLines > 0 && len(minors) >= refs.MinorLines {
+					continue
+				}
+				minors = append(minors, mm)
+			}
+			if patches[mm] < refs.PatchesPerMinor {
+				patches[mm]++
+				res = append(res, t)
+			}
+		}
+		tags = res
+	}
+	maxTags := refs.MaxTags
+	if maxTags == 0 {
+		maxTags = source.DefaultMaxTags
+	}
+	if len(tags) > maxTags {
+		tags = tags[:maxTags]
+	}
+	return tags, nil
+}
+
 // dedupTagsBySHA deduplicates tags that point to the same commit SHA.
 // When multiple tags share a SHA, the one with the lexicographically
 // smallest name is kept, so the result is deterministic regardless of
 // the input order returned by the GitHub API.
-func dedupTagsBySHA(tags []github.Tag) []github.Tag {
+func dedupTagsBySHA(tags []forge.Tag) []forge.Tag {
 	idx := make(map[string]int, len(tags))
-	res := make([]github.Tag, 0, len(tags))
+	res := make([]forge.Tag, 0, len(tags))
 	for _, t := range tags {
 		if i, ok := idx[t.Commit.SHA]; ok {
 			if t.Name < res[i].Name {
@@ -431,139 +747,473 @@ func dedupTagsBySHA(tags []github.Tag) []github.Tag {
 	return res
 }
 
-// updateGitHubRepo expects url to be "https://github.com/<ORG>/<REPO>".
-func (c *Cache) updateGitHubRepo(ctx context.Context, urlStr, category string) error {
-	repo, err := github.NewRepo(urlStr)
+// updateRepo expects r.URL to be a repository URL on a forge known to
+// [forge.ParseRepoURL], e.g. "https://github.com/<OWNER>/<REPO>".
+func (c *Cache) updateRepo(ctx context.Context, r source.Repository) error {
+	if c.opts.fetcher == FetcherGoProxy {
+		err := c.updateRepoGoProxy(ctx, r)
+		if !errors.Is(err, goproxy.ErrDirect) {
+			return err
+		}
+		c.onProgress(ctx, progress.Event{Message: fmt.Sprintf("%s: %v; falling back to the forge API", r.URL, err)})
+	}
+	repo, err := forge.ParseRepoURL(r.URL)
 	if err != nil {
 		return err
 	}
-	tags, err := repo.Tags(ctx, c.httpOpts()...)
+	client, err := forge.NewClient(*repo)
 	if err != nil {
 		return err
 	}
-	tags = filterPrelease(tags)
-	tags = dedupTagsBySHA(tags)
-	const maxTags = 10
-	if len(tags) > maxTags {
-		tags = tags[:maxTags]
+	tags, err := client.Tags(ctx, forge.DefaultMaxTagPages, c.conditionalHTTPOpts()...)
+	if err != nil {
+		return err
 	}
-	g, ctx := errgroup.WithContext(ctx)
+	refs := r.Refs
+	if c.opts.defaultBranch {
+		refs.DefaultBranch = true
+	}
+	tags, err = selectTags(tags, refs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.URL, err)
+	}
+	branches, err := c.selectBranches(ctx, client, refs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.URL, err)
+	}
+	// The network access for the tags is skipped when the selected tags and
+	// the repository configuration are unchanged since the last successful
+	// update, unless their snapshots have been removed since then.
+	tagsDigest, err := digest(struct {
+		Repository      source.Repository
+		DiscoverModules bool
+		Tags            []forge.Tag
+	}{r, c.opts.discoverModules, tags})
+	if err != nil {
+		return err
+	}
+	tagsUnchanged := c.state != nil && c.state.repoUnchanged(r.URL, tagsDigest)
+	if tagsUnchanged {
+		c.onProgress(ctx, progress.Event{Message: fmt.Sprintf("%s: tags unchanged", repo)})
+	}
+	g, gctx := errgroup.WithContext(ctx)
 	for _, tag := range tags {
 		g.Go(func() error {
-			return c.updateGitHubRepoTag(ctx, repo, tag, category)
+			meta := Meta{Repo: *repo, Tag: tag, Category: r.Category}
+			if tagsUnchanged {
+				if done, err := c.commitSnapshotsExist(r, meta); done || err != nil {
+					return err
+				}
+			}
+			return c.updateRepoCommit(gctx, client, r, meta)
+		})
+	}
+	for _, branch := range branches {
+		g.Go(func() error {
+			br, err := client.Branch(gctx, branch, c.conditionalHTTPOpts()...)
+			if err != nil {
+				return err
+			}
+			meta := Meta{Repo: *repo, Branch: br, Category: r.Category}
+			if err = c.updateRepoCommit(gctx, client, r, meta); err != nil {
+				return err
+			}
+			return c.pruneBranchSnapshots(*repo, *br)
 		})
 	}
-	return g.Wait()
+	if err = g.Wait(); err != nil {
+		return err
+	}
+	if c.state != nil && !tagsUnchanged {
+		c.state.setRepo(r.URL, tagsDigest)
+	}
+	return nil
+}
+
+// selectBranches returns the names of the branches to index, as configured by
+// refs.
+func (c *Cache) selectBranches(ctx context.Context, client forge.Client, refs source.Refs) ([]string, error) {
+	var res []string
+	if refs.DefaultBranch {
+		branch, err := client.DefaultBranch(ctx, c.conditionalHTTPOpts()...)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, branch)
+	}
+	if refs.BranchPattern != "" {
+		branchRE, err := regexp.Compile(refs.BranchPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid branch pattern: %w", err)
+		}
+		branches, err := client.Branches(ctx, forge.DefaultMaxTagPages, c.conditionalHTTPOpts()...)
+		if err != nil {
+			return nil, err
+		}
+		for _, branch := range branches {
+			if branchRE.MatchString(branch) && !slices.Contains(res, branch) {
+				res = append(res, branch)
+			}
+		}
+	}
+	return res, nil
 }
 
-func (c *Cache) updateGitHubRepoTag(ctx context.Context, repo *github.Repo, tag github.Tag, category string) error {
-	dir := filepath.Join(c.LocalDir(), "github.com", repo.Owner, repo.Repo, tag.Commit.SHA)
-	if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+// pruneBranchSnapshots removes the snapshots of the previous tips of br, so
+// that adoption reverted on the branch is not recognized anymore.
+// The snapshots of the tags are never removed, as they are stored apart from
+// the branches (see [Cache.snapshotDir]).
+func (c *Cache) pruneBranchSnapshots(repo forge.Repo, br forge.Branch) error {
+	branchDir := c.branchDir(repo, br.Name)
+	ents, err := os.ReadDir(branchDir)
+	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	for _, ent := range ents {
+		if !ent.IsDir() || ent.Name() == br.Commit.SHA {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(branchDir, ent.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repoDir returns the directory of the snapshots of repo.
+func (c *Cache) repoDir(repo forge.Repo) string {
+	return filepath.Join(c.LocalDir(), repo.Hostname(), filepath.FromSlash(repo.Owner), repo.Repo)
+}
+
+// branchDirPrefix is the prefix of the directories of the branch snapshots in
+// the directory of a repository, which cannot clash with the commit SHAs of
+// the tag snapshots.
+const branchDirPrefix = "branch-"
+
+// branchDir returns the directory of the snapshots of the tips of the branch.
+func (c *Cache) branchDir(repo forge.Repo, branch string) string {
+	return filepath.Join(c.repoDir(repo), branchDirPrefix+url.PathEscape(branch))
+}
+
+// updateRepoCommit fetches the go.mod and go.sum (and vendor/modules.txt
+// with [source.Repository.Vendor]) of each module directory of r, at the tag or
+// the branch tip of meta. With [source.Repository.DiscoverModules], the module
+// directories are discovered from the tree of the commit.
+func (c *Cache) updateRepoCommit(ctx context.Context, client forge.Client, r source.Repository, meta Meta) error {
+	var mods []moduleDir
+	if r.DiscoverModules || c.opts.discoverModules {
+		entries, truncated, err := client.Tree(ctx, meta.Commit(), c.httpOpts()...)
+		if err != nil {
+			return err
+		}
+		if truncated {
+			slog.WarnContext(ctx, "the tree listing is truncated; some nested modules may be missing",
+				"repo", meta.Repo.String(), "commit", meta.Commit())
+		}
+		mods = discoverModules(entries)
+	} else {
+		for _, modDir := range r.ModuleDirs() {
+			mods = append(mods, moduleDir{dir: modDir, vendor: r.Vendor})
+		}
+	}
+	for _, mod := range mods {
+		m := meta
+		m.Subpath = mod.dir
+		if err := c.updateRepoModule(ctx, client, m, mod.vendor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitSnapshotsExist reports whether the snapshots of the modules of r at
+// the commit of meta have been stored, without accessing the network.
+// With the module discovery, any snapshot of the commit counts, as the
+// discovered modules are not known without listing the tree.
+func (c *Cache) commitSnapshotsExist(r source.Repository, meta Meta) (bool, error) {
+	if r.DiscoverModules || c.opts.discoverModules {
+		found := false
+		err := filepath.WalkDir(c.snapshotDir(meta), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && d.Name() == MetaFilename {
+				found = true
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return found, err
+	}
+	for _, modDir := range r.ModuleDirs() {
+		m := meta
+		m.Subpath = modDir
+		if done, err := c.snapshotExists(m); !done || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// moduleDir is a directory of a go.mod in a repository.
+type moduleDir struct {
+	dir    string // slash-separated, relative to the repository root
+	vendor bool   // has vendor/modules.txt
+}
+
+// discoverModules returns the module directories in a git tree, skipping the
+// directories ignored by the go command (vendor, testdata, and "." or "_"
+// prefixed ones).
+func discoverModules(entries []forge.TreeEntry) []moduleDir {
+	blobs := make(map[string]bool)
+	for _, e := range entries {
+		if e.Type == "blob" {
+			blobs[e.Path] = true
+		}
+	}
+	var res []moduleDir
+	for _, e := range entries {
+		if e.Type != "blob" || path.Base(e.Path) != "go.mod" {
+			continue
+		}
+		dir := path.Dir(e.Path)
+		if dir == "." {
+			dir = ""
+		}
+		ignored := slices.ContainsFunc(strings.Split(dir, "/"), func(elem string) bool {
+			return elem == "vendor" || elem == "testdata" || strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_")
+		})
+		if ignored {
+			continue
+		}
+		res = append(res, moduleDir{dir: dir, vendor: blobs[path.Join(dir, vendorModulesTxt)]})
+	}
+	slices.SortFunc(res, func(a, b moduleDir) int { return strings.Compare(a.dir, b.dir) })
+	return res
+}
+
+// updateRepoModule fetches the files of the module, and stores them with
+// [Cache.storeSnapshot].
+func (c *Cache) updateRepoModule(ctx context.Context, client forge.Client, meta Meta, vendor bool) error {
+	commit, modDir := meta.Commit(), meta.Subpath
+	if done, err := c.snapshotExists(meta); done || err != nil {
 		return err
 	}
-	for _, p := range []string{"go.mod", "go.sum"} {
-		urlStr := repo.ContentURL(tag.Commit.SHA, p)
-		b, err := netutil.Get(ctx, urlStr, c.httpOpts()...)
+	files := []string{"go.mod", "go.sum"}
+	if vendor {
+		files = append(files, vendorModulesTxt)
+	}
+	var fetched []snapshotFile
+	for _, p := range files {
+		urlStr := client.ContentURL(commit, path.Join(modDir, p))
+		b, err := netutil.Get(ctx, urlStr, append(c.httpOpts(), netutil.WithHostToken(meta.Repo.Hostname()))...)
 		if err != nil {
 			var err2 *netutil.UnexpectedStatusCodeError
 			if errors.As(err, &err2) && err2.StatusCode == 404 {
-				// Not Go code
+				// Not Go code, or not vendored
 				break
 			}
 			return err
 		}
-		f := filepath.Join(dir, p)
-		if err = os.WriteFile(f, b, 0o644); err != nil {
+		fetched = append(fetched, snapshotFile{path: p, data: b})
+	}
+	return c.storeSnapshot(ctx, meta, fetched)
+}
+
+// snapshotDir returns the directory of the snapshot of meta:
+// "<REPO DIR>/<COMMIT>/<SUBPATH>" for a tag, and
+// "<REPO DIR>/branch-<BRANCH>/<COMMIT>/<SUBPATH>" for a branch tip.
+// A tag and a branch at the same commit have distinct snapshots, so that
+// pruning the branch never removes the tag.
+func (c *Cache) snapshotDir(meta Meta) string {
+	dir := c.repoDir(meta.Repo)
+	if meta.Branch != nil {
+		dir = c.branchDir(meta.Repo, meta.Branch.Name)
+	}
+	return filepath.Join(dir, meta.Commit(), filepath.FromSlash(meta.Subpath))
+}
+
+// snapshotExists reports whether the snapshot of meta has been stored.
+func (c *Cache) snapshotExists(meta Meta) (bool, error) {
+	_, err := os.Stat(filepath.Join(c.snapshotDir(meta), MetaFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// snapshotFile is a file of a snapshot.
+type snapshotFile struct {
+	path string // slash-separated, relative to the module directory
+	data []byte
+}
+
+// storeSnapshot writes the files and the meta into a staging directory, and
+// then moves them into the cache with [commitSnapshot].
+func (c *Cache) storeSnapshot(ctx context.Context, meta Meta, files []snapshotFile) error {
+	stagingDir := filepath.Join(c.LocalDir(), stagingDirName)
+	if err := os.MkdirAll(stagingDir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(stagingDir, "snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	var paths []string
+	for _, sf := range files {
+		f := filepath.Join(tmp, filepath.FromSlash(sf.path))
+		if err = os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
 			return err
 		}
-	}
-	meta := &Meta{
-		Repo:     *repo,
-		Tag:      tag.Compact(),
-		Category: category,
+		if err = os.WriteFile(f, sf.data, 0o644); err != nil {
+			return err
+		}
+		paths = append(paths, sf.path)
 	}
 	metaB, err := json.Marshal(meta)
 	if err != nil {
 		return err
 	}
-	metaF := filepath.Join(dir, MetaFilename)
-	if err := os.WriteFile(metaF, metaB, 0o644); err != nil {
+	if err = os.WriteFile(filepath.Join(tmp, MetaFilename), metaB, 0o644); err != nil {
+		return err
+	}
+	if err = commitSnapshot(tmp, c.snapshotDir(meta), paths); err != nil {
 		return err
 	}
 	c.onProgress(ctx, progress.Event{
-		Message: fmt.Sprintf("%s/%s %s %s (%s)",
-			repo.Owner, repo.Repo, tag.Name, tag.Commit.SHA, category),
+		Message: fmt.Sprintf("%s %s %s (%s)",
+			meta.Repo, path.Join(meta.Ref(), meta.Subpath), meta.Commit(), meta.Category),
 	})
 	return nil
 }
 
+// stagingDirName is the directory in [Cache.LocalDir] for the snapshots being
+// fetched. Removed on every update.
+const stagingDirName = ".tmp"
+
+// commitSnapshot moves the snapshot staged in tmp to dir.
+// When dir already exists (e.g., it contains the snapshot of a nested module),
+// the files are moved one by one, and [MetaFilename] is moved at last so that
+// a partial snapshot is never indexed nor skipped by the next update.
+func commitSnapshot(tmp, dir string, files []string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err == nil {
+		return nil
+	} else if _, statErr := os.Stat(dir); statErr != nil {
+		return err
+	}
+	for _, p := range append(files, MetaFilename) {
+		dst := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(tmp, filepath.FromSlash(p)), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 const MetaFilename = "gosocialcheck-meta.json"
 
 type Meta struct {
-	Repo     github.Repo `json:"repo"`
-	Tag      github.Tag  `json:"tag"`
-	Category string      `json:"category"`
+	Repo forge.Repo `json:"repo"`
+	// Tag is the zero value for the snapshot of a branch tip.
+	Tag forge.Tag `json:"tag"`
+	// Branch is set for the snapshot of a branch tip (see
+	// [source.Refs.DefaultBranch]), instead of Tag.
+	Branch *forge.Branch `json:"branch,omitempty"`
+	// Subpath is the slash-separated directory of the module (go.mod) in the
+	// repository, e.g. "staging/src/k8s.io/api". Empty for the root module.
+	Subpath  string `json:"subpath,omitempty"`
+	Category string `json:"category"`
 }
 
-func (c *Cache) Lookup(ctx context.Context, sum string) ([]Meta, error) {
-	if !strings.HasPrefix(sum, "h1:") || !strings.HasSuffix(sum, "=") {
-		return nil, fmt.Errorf("expected h1 sum, got %q", sum)
+// Commit returns the commit SHA of the snapshot.
+func (m Meta) Commit() string {
+	if m.Branch != nil {
+		return m.Branch.Commit.SHA
 	}
-	dataDir := c.dataDir()
-	goSumFiles, err := c.lookupGoSumFiles(ctx, dataDir, sum)
+	return m.Tag.Commit.SHA
+}
+
+// Ref returns the tag name, or "branch:<BRANCH>@<SHORT SHA>" for the snapshot
+// of a branch tip.
+func (m Meta) Ref() string {
+	if m.Branch == nil {
+		return m.Tag.Name
+	}
+	sha := m.Branch.Commit.SHA
+	if len(sha) > 12 {
+		sha = sha[:12]
+	}
+	return "branch:" + m.Branch.Name + "@" + sha
+}
+
+// LookupVersions returns the versions of modPath used by trusted projects,
+// mapped to the metadata of the snapshots whose go.sum records a hash of them.
+// The snapshots that only vendor a version are skipped.
+func (c *Cache) LookupVersions(ctx context.Context, modPath string) (map[string][]Meta, error) {
+	idx, err := c.loadIndex(ctx, c.dataDir())
 	if err != nil {
 		return nil, err
 	}
-	var res []Meta
-	for _, goSumFile := range goSumFiles {
-		dir := filepath.Join(dataDir, filepath.Clean(filepath.Dir(goSumFile)))
-		f := filepath.Join(dir, MetaFilename)
-		b, err := os.ReadFile(f)
-		if err != nil {
-			return res, err
+	res := make(map[string][]Meta)
+	for v, mv := range idx.Modules[modPath] {
+		if hit := idx.metas(mv.summed(), c.trusts); len(hit) > 0 {
+			res[v] = hit
 		}
-		var m Meta
-		if err = json.Unmarshal(b, &m); err != nil {
-			return res, err
-		}
-		res = append(res, m)
 	}
 	return res, nil
 }
 
-func (c *Cache) lookupGoSumFiles(ctx context.Context, dataDir, sum string) ([]string, error) {
-	var stdout, stderr bytes.Buffer
-	// The remote cache directory is a real git working tree, so plain
-	// `git grep` confines the search to tracked files (and skips .git/).
-	// The local cache directory is not a git repo, so we need --no-index.
-	args := []string{"-C", dataDir, "grep", "--name-only"}
-	if c.ReadMode() == ModeLocal {
-		args = append(args, "--no-index")
+// String returns "<REPO>[/<SUBPATH>] <TAG> (<CATEGORY>)", or
+// "<REPO>[/<SUBPATH>] branch:<BRANCH>@<SHORT SHA> <COMMIT DATE> (<CATEGORY>)",
+// where <REPO> is formatted by [forge.Repo.String], e.g. "containerd/containerd".
+func (m Meta) String() string {
+	ref := m.Ref()
+	if m.Branch != nil && !m.Branch.Commit.Time.IsZero() {
+		ref += " " + m.Branch.Commit.Time.UTC().Format(time.DateOnly)
 	}
-	args = append(args, sum)
-	cmd := exec.CommandContext(ctx, "git", args...)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		exitCode := -1
-		if cmd.ProcessState != nil {
-			exitCode = cmd.ProcessState.ExitCode()
-		}
-		if exitCode == 1 && stderr.String() == "" {
-			// failed successfully
-			return nil, nil
+	return fmt.Sprintf("%s %s (%s)", path.Join(m.Repo.String(), m.Subpath), ref, m.Category)
+}
+
+// Lookup returns the metadata of every cached trusted-project snapshot whose
+// go.sum contains sum.
+func (c *Cache) Lookup(ctx context.Context, sum string) ([]Meta, error) {
+	if !strings.HasPrefix(sum, "h1:") || !strings.HasSuffix(sum, "=") {
+		return nil, fmt.Errorf("expected h1 sum, got %q", sum)
+	}
+	idx, err := c.loadIndex(ctx, c.dataDir())
+	if err != nil {
+		return nil, err
+	}
+	return idx.lookup(sum, c.trusts), nil
+}
+
+// BatchLookup is like [Cache.Lookup] but resolves many sums with a single load of
+// the index. Sums with no hit are omitted from the result.
+func (c *Cache) BatchLookup(ctx context.Context, sums []string) (map[string][]Meta, error) {
+	for _, sum := range sums {
+		if !strings.HasPrefix(sum, "h1:") || !strings.HasSuffix(sum, "=") {
+			return nil, fmt.Errorf("expected h1 sum, got %q", sum)
 		}
-		return nil, fmt.Errorf("failed to run %v (stderr=%q)", cmd.Args, stderr.String())
 	}
-	sc := bufio.NewScanner(&stdout)
-	var res []string
-	for sc.Scan() {
-		line := sc.Text()
-		line = strings.TrimSpace(line)
-		res = append(res, line)
+	idx, err := c.loadIndex(ctx, c.dataDir())
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]Meta)
+	for _, sum := range sums {
+		if hit := idx.lookup(sum, c.trusts); len(hit) > 0 {
+			res[sum] = hit
+		}
 	}
-	return res, sc.Err()
+	return res, nil
 }
@@ -1,15 +1,33 @@
 package cache
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"gotest.tools/v3/assert"
 
-	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/github"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/goproxy"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
 )
 
-func tagWithSHA(name, sha string) github.Tag {
-	var t github.Tag
+func tagWithSHA(name, sha string) forge.Tag {
+	var t forge.Tag
 	t.Name = name
 	t.Commit.SHA = sha
 	return t
@@ -22,51 +40,51 @@ func TestDedupTagsBySHA(t *testing.T) {
 	const sharedSHA = "323213fe0f87ceb825c29c9e8094439d7f8edb56"
 	cases := []struct {
 		name string
-		in   []github.Tag
-		want []github.Tag
+		in   []forge.Tag
+		want []forge.Tag
 	}{
 		{
 			name: "no duplicates",
-			in: []github.Tag{
+			in: []forge.Tag{
 				tagWithSHA("v1.0.0", "aaa"),
 				tagWithSHA("v0.9.0", "bbb"),
 			},
-			want: []github.Tag{
+			want: []forge.Tag{
 				tagWithSHA("v1.0.0", "aaa"),
 				tagWithSHA("v0.9.0", "bbb"),
 			},
 		},
 		{
 			name: "shared SHA keeps lexicographically smallest name",
-			in: []github.Tag{
+			in: []forge.Tag{
 				tagWithSHA("agent/0.84.3", sharedSHA),
 				tagWithSHA("agent/0.84.1", sharedSHA),
 				tagWithSHA("agent/0.84.2", sharedSHA),
 			},
-			want: []github.Tag{
+			want: []forge.Tag{
 				tagWithSHA("agent/0.84.1", sharedSHA),
 			},
 		},
 		{
 			name: "deterministic regardless of input order",
-			in: []github.Tag{
+			in: []forge.Tag{
 				tagWithSHA("agent/0.84.1", sharedSHA),
 				tagWithSHA("agent/0.84.3", sharedSHA),
 				tagWithSHA("agent/0.84.2", sharedSHA),
 			},
-			want: []github.Tag{
+			want: []forge.Tag{
 				tagWithSHA("agent/0.84.1", sharedSHA),
 			},
 		},
 		{
 			name: "preserves first-occurrence order across distinct SHAs",
-			in: []github.Tag{
+			in: []forge.Tag{
 				tagWithSHA("v1.0.0", "aaa"),
 				tagWithSHA("v0.9.1", "bbb"),
 				tagWithSHA("v0.9.0", "bbb"),
 				tagWithSHA("v0.8.0", "ccc"),
 			},
-			want: []github.Tag{
+			want: []forge.Tag{
 				tagWithSHA("v1.0.0", "aaa"),
 				tagWithSHA("v0.9.0", "bbb"),
 				tagWithSHA("v0.8.0", "ccc"),
@@ -80,3 +98,684 @@ func TestDedupTagsBySHA(t *testing.T) {
 		})
 	}
 }
+
+func writeSnapshotT(t *testing.T, dir string, m Meta, goSum string) {
+	t.Helper()
+	assert.NilError(t, os.MkdirAll(dir, 0o755))
+	metaB, err := json.Marshal(m)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, MetaFilename), metaB, 0o644))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "go.sum"), []byte(goSum), 0o644))
+}
+
+func TestLookupIndex(t *testing.T) {
+	ctx := context.TODO()
+	cacheDir := t.TempDir()
+	c, err := New(WithDir(cacheDir), WithMode(ModeLocal))
+	assert.NilError(t, err)
+
+	m1 := Meta{Repo: forge.Repo{Owner: "containerd", Repo: "containerd"}, Tag: tagWithSHA("v2.0.0", "aaa"), Category: "cncf.io::graduated"}
+	m2 := Meta{Repo: forge.Repo{Owner: "kubernetes", Repo: "kubernetes"}, Tag: tagWithSHA("v1.33.0", "bbb"), Category: "cncf.io::graduated"}
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "containerd", "containerd", "aaa"), m1,
+		"example.com/foo v1.0.0 h1:foo=\nexample.com/foo v1.0.0/go.mod h1:foomod=\n")
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "kubernetes", "kubernetes", "bbb"), m2,
+		"example.com/foo v1.0.0 h1:foo=\nexample.com/bar v1.0.0 h1:bar=\n")
+
+	// The index is built lazily when absent (e.g. a cache from an older release),
+	// without modifying the cache directory.
+	lastUpdated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NilError(t, os.Chtimes(c.LocalDir(), lastUpdated, lastUpdated))
+	got, err := c.Lookup(ctx, "h1:foo=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{m1, m2}, got)
+	_, err = os.Stat(c.indexFile(c.LocalDir()))
+	assert.NilError(t, err)
+	_, err = os.Stat(filepath.Join(c.LocalDir(), "gosocialcheck-index.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	got2, err := c.LastUpdated()
+	assert.NilError(t, err)
+	assert.Assert(t, got2.Equal(lastUpdated))
+
+	// A fresh Cache reads the persisted index.
+	c2, err := New(WithDir(cacheDir), WithMode(ModeLocal))
+	assert.NilError(t, err)
+	got, err = c2.Lookup(ctx, "h1:bar=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{m2}, got)
+	got, err = c2.Lookup(ctx, "h1:foomod=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{m1}, got)
+	got, err = c2.Lookup(ctx, "h1:missing=")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(got))
+
+	_, err = c2.Lookup(ctx, "not-a-sum")
+	assert.ErrorContains(t, err, "expected h1 sum")
+
+	batch, err := c2.BatchLookup(ctx, []string{"h1:foo=", "h1:bar=", "h1:missing="})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string][]Meta{
+		"h1:foo=": {m1, m2},
+		"h1:bar=": {m2},
+	}, batch)
+
+	// "/go.mod"-only entries do not count as adoption of a version.
+	versions, err := c2.LookupVersions(ctx, "example.com/foo")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string][]Meta{"v1.0.0": {m1, m2}}, versions)
+	versions, err = c2.LookupVersions(ctx, "example.com/missing")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(versions))
+}
+
+func TestExplain(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal))
+	assert.NilError(t, err)
+
+	m1 := Meta{Repo: forge.Repo{Owner: "containerd", Repo: "containerd"}, Tag: tagWithSHA("v2.0.0", "aaa"), Category: "cncf.io::graduated"}
+	m2 := Meta{Repo: forge.Repo{Owner: "kubernetes", Repo: "kubernetes"}, Tag: tagWithSHA("v1.33.0", "bbb"), Category: "cncf.io::graduated"}
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "containerd", "containerd", "aaa"), m1,
+		"example.com/foo v1.1.0 h1:foo110=\nexample.com/foo v1.2.0/go.mod h1:foo120mod=\n")
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "kubernetes", "kubernetes", "bbb"), m2,
+		"example.com/foo v1.0.0 h1:foo100=\nexample.com/foo v1.1.0 h1:foo110=\n")
+
+	e, err := c.Explain(ctx, "example.com/foo", "v1.1.0", "")
+	assert.NilError(t, err)
+	assert.Assert(t, e.Adopted())
+	assert.DeepEqual(t, []Meta{m1, m2}, e.Hits)
+	assert.Equal(t, 0, len(e.OtherVersions))
+
+	// A miss lists the other versions, newest first. The "/go.mod"-only entry
+	// does not count as adoption.
+	e, err = c.Explain(ctx, "example.com/foo", "v1.2.0", "")
+	assert.NilError(t, err)
+	assert.Assert(t, !e.Adopted())
+	assert.DeepEqual(t, []VersionHits{
+		{Version: "v1.1.0", Hits: []Meta{m1, m2}},
+		{Version: "v1.0.0", Hits: []Meta{m2}},
+	}, e.OtherVersions)
+
+	var b strings.Builder
+	assert.NilError(t, e.WriteText(&b))
+	assert.Equal(t, `example.com/foo@v1.2.0 is not adopted by a trusted project
+Other versions of example.com/foo adopted by trusted projects:
+  - v1.1.0: containerd/containerd v2.0.0 (cncf.io::graduated), kubernetes/kubernetes v1.33.0 (cncf.io::graduated)
+  - v1.0.0: kubernetes/kubernetes v1.33.0 (cncf.io::graduated)
+`, b.String())
+
+	// The hits are matched on the sum, as Lookup does, so a snapshot with
+	// another hash of the version is not counted as adoption.
+	m3 := Meta{Repo: forge.Repo{Owner: "example", Repo: "mismatched"}, Tag: tagWithSHA("v0.1.0", "ccc"), Category: "cncf.io::graduated"}
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "example", "mismatched", "ccc"), m3,
+		"example.com/foo v1.3.0 h1:foo130-mismatched=\n")
+	assert.NilError(t, c.reindex(ctx, c.LocalDir()))
+	e, err = c.Explain(ctx, "example.com/foo", "v1.3.0", "h1:foo130=")
+	assert.NilError(t, err)
+	hits, err := c.Lookup(ctx, "h1:foo130=")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(hits))
+	assert.Assert(t, !e.Adopted())
+	assert.DeepEqual(t, []Meta{m3}, e.Unmatched)
+	b.Reset()
+	assert.NilError(t, e.WriteText(&b))
+	assert.Assert(t, strings.HasPrefix(b.String(), `example.com/foo@v1.3.0 (h1:foo130=) is not adopted by a trusted project
+Used without a matching go.sum hash (vendored only, or another hash; not counted as adoption) by 1 snapshot(s):
+  - example/mismatched v0.1.0 (cncf.io::graduated)
+`), b.String())
+}
+
+func TestLookupRecords(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal))
+	assert.NilError(t, err)
+
+	m1 := Meta{Repo: forge.Repo{Owner: "containerd", Repo: "containerd"}, Tag: tagWithSHA("v2.0.0", "aaa"), Category: "cncf.io::graduated"}
+	m2 := Meta{Repo: forge.Repo{Owner: "kubernetes", Repo: "kubernetes"}, Tag: tagWithSHA("v1.33.0", "bbb"), Category: "cncf.io::graduated"}
+	dir1 := filepath.Join(c.LocalDir(), "github.com", "containerd", "containerd", "aaa")
+	writeSnapshotT(t, dir1, m1,
+		"example.com/foo v1.1.0 h1:foo110=\nexample.com/foo v1.1.0/go.mod h1:foo110mod=\nexample.com/foo v1.3.0/go.mod h1:foo130mod=\n")
+	assert.NilError(t, os.WriteFile(filepath.Join(dir1, "go.mod"),
+		[]byte("module github.com/containerd/containerd\n\nrequire example.com/foo v1.1.0\n"), 0o644))
+	// No go.mod: every module is treated as indirect.
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "kubernetes", "kubernetes", "bbb"), m2,
+		"example.com/foo v1.0.0 h1:foo100=\nexample.com/foo v1.1.0 h1:foo110=\nexample.com/foo v1.2.0 h1:foo120=\n")
+	// Each record has the hash recorded by its own snapshot.
+	m3 := Meta{Repo: forge.Repo{Owner: "example", Repo: "mismatched"}, Tag: tagWithSHA("v0.1.0", "ccc"), Category: "cncf.io::graduated"}
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "example", "mismatched", "ccc"), m3,
+		"example.com/foo v1.1.0 h1:foo110-mismatched=\nexample.com/foo v1.1.0/go.mod h1:foo110mod-mismatched=\n")
+
+	got, err := c.LookupPath(ctx, "example.com/foo")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Record{
+		{Path: "example.com/foo", Version: "v1.2.0", Sum: "h1:foo120=", Meta: m2},
+		{Path: "example.com/foo", Version: "v1.1.0", Sum: "h1:foo110=", GoModSum: "h1:foo110mod=", Direct: true, Meta: m1},
+		{Path: "example.com/foo", Version: "v1.1.0", Sum: "h1:foo110-mismatched=", GoModSum: "h1:foo110mod-mismatched=", Meta: m3},
+		{Path: "example.com/foo", Version: "v1.1.0", Sum: "h1:foo110=", Meta: m2},
+		{Path: "example.com/foo", Version: "v1.0.0", Sum: "h1:foo100=", Meta: m2},
+	}, got)
+
+	got, err = c.LookupRange(ctx, "example.com/foo", "v1.0.1", "v1.1.0")
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(got))
+	assert.Equal(t, "v1.1.0", got[0].Version)
+
+	got, err = c.LookupRange(ctx, "example.com/foo", "v1.2.1", "")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(got))
+
+	_, err = c.LookupRange(ctx, "example.com/foo", "1.0", "")
+	assert.ErrorContains(t, err, "invalid version")
+
+	// The "/go.mod"-only v1.3.0 line does not count as adoption.
+	latest, err := c.Latest(ctx, "example.com/foo")
+	assert.NilError(t, err)
+	assert.Equal(t, "v1.2.0", latest)
+	latest, err = c.Latest(ctx, "example.com/missing")
+	assert.NilError(t, err)
+	assert.Equal(t, "", latest)
+}
+
+func TestCategories(t *testing.T) {
+	ctx := context.TODO()
+	cacheDir := t.TempDir()
+	c, err := New(WithDir(cacheDir), WithMode(ModeLocal))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, categories.Default, c.Categories())
+
+	m1 := Meta{Repo: forge.Repo{Owner: "containerd", Repo: "containerd"}, Tag: tagWithSHA("v2.0.0", "aaa"), Category: categories.CNCFGraduated}
+	m2 := Meta{Repo: forge.Repo{Owner: "example", Repo: "sandboxed"}, Tag: tagWithSHA("v0.1.0", "bbb"), Category: categories.CNCFSandbox}
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "containerd", "containerd", "aaa"), m1,
+		"example.com/foo v1.0.0 h1:foo=\n")
+	writeSnapshotT(t, filepath.Join(c.LocalDir(), "github.com", "example", "sandboxed", "bbb"), m2,
+		"example.com/foo v1.0.0 h1:foo=\nexample.com/bar v1.0.0 h1:bar=\n")
+
+	// Sandbox snapshots are ignored by default.
+	got, err := c.Lookup(ctx, "h1:foo=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{m1}, got)
+	got, err = c.Lookup(ctx, "h1:bar=")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(got))
+	e, err := c.Explain(ctx, "example.com/bar", "", "")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(e.OtherVersions))
+
+	c2, err := New(WithDir(cacheDir), WithMode(ModeLocal), WithCategories(categories.CNCFGraduated, categories.CNCFSandbox))
+	assert.NilError(t, err)
+	got, err = c2.Lookup(ctx, "h1:foo=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{m1, m2}, got)
+	latest, err := c2.Latest(ctx, "example.com/bar")
+	assert.NilError(t, err)
+	assert.Equal(t, "v1.0.0", latest)
+}
+
+func TestIndexVendor(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal), WithCategories(categories.GoToolchain))
+	assert.NilError(t, err)
+
+	m := Meta{Repo: forge.Repo{Owner: "golang", Repo: "go"}, Tag: tagWithSHA("go1.24.0", "aaa"), Category: categories.GoToolchain}
+	dir := filepath.Join(c.LocalDir(), "github.com", "golang", "go", "aaa", "src", "cmd")
+	writeSnapshotT(t, dir, m, "golang.org/x/tools v0.30.0 h1:tools=\n")
+	assert.NilError(t, os.MkdirAll(filepath.Join(dir, "vendor"), 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "vendor", "modules.txt"), []byte(`# golang.org/x/tools v0.30.0
+## explicit; go 1.22.0
+golang.org/x/tools/go/analysis
+# golang.org/x/mod v0.23.0
+## explicit; go 1.22.0
+golang.org/x/mod/semver
+# example.com/old v1.0.0 => example.com/new v1.1.0
+# example.com/local v1.0.0 => ./local
+`), 0o644))
+
+	got, err := c.Lookup(ctx, "h1:tools=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{m}, got)
+
+	// Vendored modules without a go.sum line are known by version only.
+	records, err := c.LookupPath(ctx, "golang.org/x/mod")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Record{{Path: "golang.org/x/mod", Version: "v0.23.0", Meta: m}}, records)
+	records, err = c.LookupPath(ctx, "example.com/new")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(records))
+	records, err = c.LookupPath(ctx, "example.com/old")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(records))
+	records, err = c.LookupPath(ctx, "golang.org/x/tools")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(records))
+
+	// Nor are they listed as adopted versions.
+	versions, err := c.LookupVersions(ctx, "golang.org/x/mod")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(versions))
+	e, err := c.Explain(ctx, "golang.org/x/mod", "v0.24.0", "")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(e.OtherVersions))
+	e, err = c.Explain(ctx, "golang.org/x/tools", "v0.31.0", "")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []VersionHits{{Version: "v0.30.0", Hits: []Meta{m}}}, e.OtherVersions)
+}
+
+func TestDiscoverModules(t *testing.T) {
+	entries := []forge.TreeEntry{
+		{Path: "go.mod", Type: "blob"},
+		{Path: "go.sum", Type: "blob"},
+		{Path: "staging", Type: "tree"},
+		{Path: "staging/src/k8s.io/api/go.mod", Type: "blob"},
+		{Path: "cmd/tool/go.mod", Type: "blob"},
+		{Path: "cmd/tool/vendor/modules.txt", Type: "blob"},
+		{Path: "cmd/tool/vendor/example.com/foo/go.mod", Type: "blob"},
+		{Path: "internal/testdata/mod/go.mod", Type: "blob"},
+		{Path: "_examples/go.mod", Type: "blob"},
+		{Path: ".github/go.mod", Type: "blob"},
+		{Path: "go.mod.tmpl", Type: "blob"},
+		{Path: "submodule", Type: "commit"},
+	}
+	assert.DeepEqual(t, []moduleDir{
+		{dir: ""},
+		{dir: "cmd/tool", vendor: true},
+		{dir: "staging/src/k8s.io/api"},
+	}, discoverModules(entries), cmp.AllowUnexported(moduleDir{}))
+}
+
+func TestIndexSubpath(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal))
+	assert.NilError(t, err)
+
+	repo := forge.Repo{Owner: "kubernetes", Repo: "kubernetes"}
+	mRoot := Meta{Repo: repo, Tag: tagWithSHA("v1.33.0", "aaa"), Category: categories.CNCFGraduated}
+	mAPI := mRoot
+	mAPI.Subpath = "staging/src/k8s.io/api"
+	repoDir := filepath.Join(c.LocalDir(), "github.com", "kubernetes", "kubernetes", "aaa")
+	writeSnapshotT(t, repoDir, mRoot, "example.com/foo v1.0.0 h1:foo=\n")
+	writeSnapshotT(t, filepath.Join(repoDir, "staging", "src", "k8s.io", "api"), mAPI, "example.com/bar v1.0.0 h1:bar=\n")
+
+	got, err := c.Lookup(ctx, "h1:bar=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{mAPI}, got)
+	assert.Equal(t, "kubernetes/kubernetes/staging/src/k8s.io/api v1.33.0 (cncf.io::graduated)", got[0].String())
+	mGitLab := Meta{Repo: forge.Repo{Forge: forge.GitLab, Host: "gitlab.com", Owner: "example/sub", Repo: "foo"}, Tag: tagWithSHA("v1.0.0", "ccc"), Subpath: "api", Category: "example.com::audited"}
+	assert.Equal(t, "gitlab.com/example/sub/foo/api v1.0.0 (example.com::audited)", mGitLab.String())
+	got, err = c.Lookup(ctx, "h1:foo=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{mRoot}, got)
+}
+
+func TestSelectTags(t *testing.T) {
+	var tags []forge.Tag
+	for i, name := range []string{
+		"v1.31.0", "v1.33.1", "v1.33.0", "v1.32.2", "v1.32.1", "v1.32.0",
+		"v1.33.2", "v1.34.0-rc.0", "v1.31.1", "latest", "v1.30.9",
+	} {
+		tags = append(tags, tagWithSHA(name, fmt.Sprintf("sha%d", i)))
+	}
+	names := func(tags []forge.Tag) []string {
+		var res []string
+		for _, t := range tags {
+			res = append(res, t.Name)
+		}
+		return res
+	}
+	cases := []struct {
+		refs source.Refs
+		want []string
+	}{
+		{
+			refs: source.Refs{MaxTags: 4},
+			want: []string{"v1.33.2", "v1.33.1", "v1.33.0", "v1.32.2"},
+		},
+		{
+			refs: source.Refs{},
+			want: []string{"v1.33.2", "v1.33.1", "v1.33.0", "v1.32.2", "v1.32.1", "v1.32.0", "v1.31.1", "v1.31.0", "v1.30.9", "latest"},
+		},
+		{
+			refs: source.Refs{PatchesPerMinor: 2},
+			want: []string{"v1.33.2", "v1.33.1", "v1.32.2", "v1.32.1", "v1.31.1", "v1.31.0", "v1.30.9"},
+		},
+		{
+			refs: source.Refs{PatchesPerMinor: 1, MinorLines: 3},
+			want: []string{"v1.33.2", "v1.32.2", "v1.31.1"},
+		},
+		{
+			refs: source.Refs{PatchesPerMinor: 2, MaxTags: 3},
+			want: []string{"v1.33.2", "v1.33.1", "v1.32.2"},
+		},
+		{
+			refs: source.Refs{TagPattern: `^v1\.32\.`},
+			want: []string{"v1.32.2", "v1.32.1", "v1.32.0"},
+		},
+	}
+	for _, tc := range cases {
+		got, err := selectTags(tags, tc.refs)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, tc.want, names(got))
+	}
+
+	goTags := []forge.Tag{
+		tagWithSHA("weekly.2012-03-27", "a"), tagWithSHA("go1.9", "b"), tagWithSHA("go1.24.1", "c"),
+		tagWithSHA("go1.25rc1", "d"), tagWithSHA("go1.24.10", "e"),
+	}
+	got, err := selectTags(goTags, source.Refs{TagPattern: `^go1\.\d+(\.\d+)?$`})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"go1.24.10", "go1.24.1", "go1.9"}, names(got))
+}
+
+func TestBranchSnapshots(t *testing.T) {
+	ctx := context.TODO()
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal))
+	assert.NilError(t, err)
+
+	repo := forge.Repo{Owner: "kubernetes", Repo: "kubernetes"}
+	branch := func(name, sha string) *forge.Branch {
+		br := &forge.Branch{Name: name}
+		br.Commit.SHA = sha
+		br.Commit.Time = time.Date(2026, 10, 16, 1, 2, 3, 0, time.UTC)
+		return br
+	}
+	mTag := Meta{Repo: repo, Tag: tagWithSHA("v1.33.0", "aaa"), Category: categories.CNCFGraduated}
+	mOld := Meta{Repo: repo, Branch: branch("master", "bbbbbbbbbbbbbbbbbbbb"), Category: categories.CNCFGraduated}
+	mRelease := Meta{Repo: repo, Branch: branch("release-1.33", "cccccccccccccccccccc"), Category: categories.CNCFGraduated}
+	// The tag and the old tip of master are at the same commit.
+	mTag.Tag.Commit.SHA = mOld.Commit()
+	for _, m := range []Meta{mTag, mOld, mRelease} {
+		writeSnapshotT(t, c.snapshotDir(m), m, "example.com/foo v1.0.0 h1:foo=\n")
+	}
+	assert.Equal(t, filepath.Join(c.LocalDir(), "github.com", "kubernetes", "kubernetes", "branch-release-1.33", "cccccccccccccccccccc"), c.snapshotDir(mRelease))
+	assert.Equal(t, "kubernetes/kubernetes v1.33.0 (cncf.io::graduated)", mTag.String())
+	assert.Equal(t, "kubernetes/kubernetes branch:master@bbbbbbbbbbbb 2026-10-16 (cncf.io::graduated)", mOld.String())
+
+	// The snapshot of the new tip replaces the old one of the same branch.
+	mNew := Meta{Repo: repo, Branch: branch("master", "dddddddddddddddddddd"), Category: categories.CNCFGraduated}
+	writeSnapshotT(t, c.snapshotDir(mNew), mNew, "example.com/foo v1.0.0 h1:foo=\n")
+	assert.NilError(t, c.pruneBranchSnapshots(repo, *mNew.Branch))
+	_, err = os.Stat(c.snapshotDir(mOld))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	// The snapshot of the tag at the old tip is kept.
+	_, err = os.Stat(c.snapshotDir(mTag))
+	assert.NilError(t, err)
+
+	got, err := c.Lookup(ctx, "h1:foo=")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Meta{mTag, mNew, mRelease}, got)
+}
+
+func TestCommitSnapshotsExist(t *testing.T) {
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal))
+	assert.NilError(t, err)
+
+	r := source.Repository{URL: "https://github.com/golang/go", Dirs: []string{"src", "src/cmd"}}
+	m := Meta{Repo: forge.Repo{Owner: "golang", Repo: "go"}, Tag: tagWithSHA("go1.24.0", "aaa"), Category: categories.GoToolchain}
+	done, err := c.commitSnapshotsExist(r, m)
+	assert.NilError(t, err)
+	assert.Assert(t, !done)
+
+	mSrc := m
+	mSrc.Subpath = "src"
+	writeSnapshotT(t, c.snapshotDir(mSrc), mSrc, "")
+	done, err = c.commitSnapshotsExist(r, m)
+	assert.NilError(t, err)
+	assert.Assert(t, !done)
+	r.DiscoverModules = true
+	done, err = c.commitSnapshotsExist(r, m)
+	assert.NilError(t, err)
+	assert.Assert(t, done)
+}
+
+func TestState(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), stateDirName, "local.json")
+	st, err := readState(stateFile)
+	assert.NilError(t, err)
+	assert.Assert(t, !st.repoUnchanged("https://github.com/example/foo", "aaa"))
+
+	st.StoreValidator("https://example.com/cncf.yaml", &netutil.Validator{ETag: `"x"`, Body: []byte("foo")})
+	st.setRepo("https://github.com/example/foo", "aaa")
+	assert.NilError(t, writeState(stateFile, st))
+
+	st, err = readState(stateFile)
+	assert.NilError(t, err)
+	v, ok := st.LoadValidator("https://example.com/cncf.yaml")
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, &netutil.Validator{ETag: `"x"`, Body: []byte("foo")}, v)
+	assert.Assert(t, st.repoUnchanged("https://github.com/example/foo", "aaa"))
+	assert.Assert(t, !st.repoUnchanged("https://github.com/example/foo", "bbb"))
+
+	// The validators not used during the update are pruned.
+	st.StoreValidator("https://example.com/asf.json", &netutil.Validator{ETag: `"y"`, Body: []byte("bar")})
+	st.pruneValidators(nil)
+	_, ok = st.LoadValidator("https://example.com/cncf.yaml")
+	assert.Assert(t, ok)
+	assert.NilError(t, writeState(stateFile, st))
+	st, err = readState(stateFile)
+	assert.NilError(t, err)
+	_, ok = st.LoadValidator("https://example.com/asf.json")
+	assert.Assert(t, ok)
+	st.pruneValidators(nil)
+	_, ok = st.LoadValidator("https://example.com/cncf.yaml")
+	assert.Assert(t, !ok)
+
+	// The validators of the sources that failed are kept.
+	st.setSource("cncf")
+	st.StoreValidator("https://api.github.com/repos/example/foo/tags", &netutil.Validator{ETag: `"z"`})
+	st.setSource("asf")
+	st.StoreValidator("https://api.github.com/repos/example/bar/tags", &netutil.Validator{ETag: `"w"`})
+	assert.NilError(t, writeState(stateFile, st))
+	st, err = readState(stateFile)
+	assert.NilError(t, err)
+	st.pruneValidators([]string{"cncf"})
+	_, ok = st.LoadValidator("https://api.github.com/repos/example/foo/tags")
+	assert.Assert(t, ok)
+	_, ok = st.LoadValidator("https://api.github.com/repos/example/bar/tags")
+	assert.Assert(t, !ok)
+
+	// Outdated states are discarded.
+	assert.NilError(t, os.WriteFile(stateFile, []byte(`{"version":0,"repos":{"https://github.com/example/foo":"aaa"}}`), 0o644))
+	st, err = readState(stateFile)
+	assert.NilError(t, err)
+	assert.Assert(t, !st.repoUnchanged("https://github.com/example/foo", "aaa"))
+}
+
+func TestCommitSnapshot(t *testing.T) {
+	stage := func(files ...string) string {
+		tmp := t.TempDir()
+		for _, f := range append(files, MetaFilename) {
+			assert.NilError(t, os.MkdirAll(filepath.Dir(filepath.Join(tmp, f)), 0o755))
+			assert.NilError(t, os.WriteFile(filepath.Join(tmp, f), []byte(f), 0o644))
+		}
+		return tmp
+	}
+	repoDir := filepath.Join(t.TempDir(), "github.com", "example", "foo", "aaa")
+
+	// The nested module is committed first; the directory does not exist yet.
+	nestedDir := filepath.Join(repoDir, "sub")
+	assert.NilError(t, commitSnapshot(stage("go.mod", "go.sum"), nestedDir, []string{"go.mod", "go.sum"}))
+	// The root module is committed into the existing directory.
+	assert.NilError(t, commitSnapshot(stage("go.mod", "go.sum", "vendor/modules.txt"), repoDir,
+		[]string{"go.mod", "go.sum", "vendor/modules.txt"}))
+
+	for _, dir := range []string{repoDir, nestedDir} {
+		for _, f := range []string{"go.mod", "go.sum", MetaFilename} {
+			b, err := os.ReadFile(filepath.Join(dir, f))
+			assert.NilError(t, err)
+			assert.Equal(t, f, string(b))
+		}
+	}
+	_, err := os.Stat(filepath.Join(repoDir, "vendor", "modules.txt"))
+	assert.NilError(t, err)
+}
+
+type failingSource struct{}
+
+func (failingSource) Name() string { return "failing" }
+
+func (failingSource) Repositories(context.Context, source.Opts) ([]source.Repository, error) {
+	return nil, errors.New("listing failed")
+}
+
+type staticSource []source.Repository
+
+func (staticSource) Name() string { return "static" }
+
+func (s staticSource) Repositories(context.Context, source.Opts) ([]source.Repository, error) {
+	return s, nil
+}
+
+func TestUpdateKeepGoing(t *testing.T) {
+	ctx := context.TODO()
+	const category = "example.com::test"
+	sources := WithSources(failingSource{}, staticSource{
+		{URL: "https://example.com/not-github", Category: category},
+		{URL: "https://example.com/not-github-either", Category: category},
+	})
+
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal), WithCategories(category), sources)
+	assert.NilError(t, err)
+	err = c.Update(ctx)
+	var ue *UpdateError
+	assert.Assert(t, errors.As(err, &ue))
+	assert.Equal(t, "failing", ue.Source)
+	_, err = os.Stat(c.indexFile(c.LocalDir()))
+	assert.Assert(t, errors.Is(err, fs.ErrNotExist))
+	// The failed update is not an update of the local cache, and the state is
+	// kept out of it.
+	lastUpdated, err := c.LastUpdated()
+	assert.NilError(t, err)
+	assert.Assert(t, lastUpdated.Equal(time.Unix(0, 0)), "lastUpdated: %v", lastUpdated)
+	_, err = os.Stat(c.stateFile())
+	assert.NilError(t, err)
+	_, err = os.Stat(filepath.Join(c.LocalDir(), legacyStateFilename))
+	assert.Assert(t, errors.Is(err, fs.ErrNotExist))
+
+	// The auto mode keeps reading the remote cache.
+	cacheDir := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(cacheDir, remoteDirName), 0o755))
+	remoteUpdated := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NilError(t, os.Chtimes(filepath.Join(cacheDir, remoteDirName), remoteUpdated, remoteUpdated))
+	localUpdated := remoteUpdated.Add(-time.Hour)
+	assert.NilError(t, os.MkdirAll(filepath.Join(cacheDir, localDirName), 0o755))
+	assert.NilError(t, os.Chtimes(filepath.Join(cacheDir, localDirName), localUpdated, localUpdated))
+	c, err = New(WithDir(cacheDir), WithMode(ModeLocal), WithCategories(category), sources)
+	assert.NilError(t, err)
+	assert.Assert(t, c.Update(ctx) != nil)
+	c, err = New(WithDir(cacheDir), WithMode(ModeAuto))
+	assert.NilError(t, err)
+	assert.Equal(t, ModeRemote, c.ReadMode())
+
+	c, err = New(WithDir(t.TempDir()), WithMode(ModeLocal), WithCategories(category), sources, WithKeepGoing(true))
+	assert.NilError(t, err)
+	err = c.Update(ctx)
+	assert.ErrorContains(t, err, "failed to list the repositories of 1 source(s), failed to update 2 of 2 repositories")
+	assert.ErrorContains(t, err, `source "static": https://example.com/not-github-either: `)
+	// The cache is updated regardless.
+	_, err = os.Stat(c.indexFile(c.LocalDir()))
+	assert.NilError(t, err)
+}
+
+// newGoProxyServer serves the modules in files, keyed by the paths such as
+// "/github.com/example/foo/@v/list". The other paths are 404.
+func newGoProxyServer(t *testing.T, files map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(b))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func moduleZip(t *testing.T, modPath, version string, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(modPath + "@" + version + "/" + name)
+		assert.NilError(t, err)
+		_, err = f.Write([]byte(content))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, zw.Close())
+	return buf.String()
+}
+
+func TestUpdateGoProxy(t *testing.T) {
+	ctx := context.TODO()
+	const category = "example.com::test"
+	const goSum = "golang.org/x/mod v0.1.0 h1:mod=\ngolang.org/x/mod v0.1.0/go.mod h1:modgomod=\n"
+	srv := newGoProxyServer(t, map[string]string{
+		"/github.com/example/foo/@v/list":                     "v1.0.0\nv1.1.0-rc.1\nv2.0.0+incompatible\n",
+		"/github.com/example/foo/@v/v1.0.0.info":              `{"Version":"v1.0.0","Origin":{"VCS":"git","Hash":"0123456789abcdef0123456789abcdef01234567"}}`,
+		"/github.com/example/foo/@v/v1.0.0.mod":               "module github.com/example/foo\n\nrequire golang.org/x/mod v0.1.0\n",
+		"/github.com/example/foo/@v/v1.0.0.zip":               moduleZip(t, "github.com/example/foo", "v1.0.0", map[string]string{"go.mod": "", "go.sum": goSum}),
+		"/github.com/example/foo/@v/v2.0.0+incompatible.info": `{"Version":"v2.0.0+incompatible"}`,
+		"/github.com/example/foo/@v/v2.0.0+incompatible.mod":  "module github.com/example/foo\n",
+		"/github.com/example/foo/@v/v2.0.0+incompatible.zip":  moduleZip(t, "github.com/example/foo", "v2.0.0+incompatible", nil),
+		"/github.com/example/foo/v3/@v/list":                  "v3.0.0\n",
+		"/github.com/example/foo/v3/@v/v3.0.0.info":           `{"Version":"v3.0.0"}`,
+		"/github.com/example/foo/v3/@v/v3.0.0.mod":            "module github.com/example/foo/v3\n\nrequire golang.org/x/mod v0.1.0\n",
+		"/github.com/example/foo/v3/@v/v3.0.0.zip":            moduleZip(t, "github.com/example/foo/v3", "v3.0.0", map[string]string{"go.sum": goSum}),
+	})
+	sources := WithSources(staticSource{{URL: "https://github.com/example/foo", Category: category}})
+
+	// "/v2" is not found, so "/v3" is not probed.
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal), WithCategories(category), sources,
+		WithFetcher(FetcherGoProxy), WithGoProxy(&goproxy.Config{Proxies: []goproxy.Proxy{{URL: srv.URL}}}))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Update(ctx))
+	metas, err := c.Lookup(ctx, "h1:mod=")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(metas))
+	assert.Equal(t, "example/foo v1.0.0 (example.com::test)", metas[0].String())
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", metas[0].Commit())
+	_, err = os.Stat(filepath.Join(c.LocalDir(), "github.com", "example", "foo", "v2.0.0+incompatible", MetaFilename))
+	assert.NilError(t, err)
+
+	// The removed snapshot is fetched again, even though the versions are unchanged.
+	assert.NilError(t, os.RemoveAll(filepath.Join(c.LocalDir(), "github.com", "example", "foo", metas[0].Commit())))
+	assert.NilError(t, c.Update(ctx))
+	metas, err = c.Lookup(ctx, "h1:mod=")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(metas))
+
+	// The modules that are not found are not fetched from the forge with "off".
+	sources = WithSources(staticSource{{URL: "https://github.com/example/bar", Category: category}})
+	c, err = New(WithDir(t.TempDir()), WithMode(ModeLocal), WithCategories(category), sources,
+		WithFetcher(FetcherGoProxy), WithGoProxy(&goproxy.Config{Proxies: []goproxy.Proxy{{URL: srv.URL}, {URL: goproxy.Off}}}))
+	assert.NilError(t, err)
+	assert.ErrorIs(t, c.Update(ctx), goproxy.ErrOff)
+}
+
+func TestUpdateAbort(t *testing.T) {
+	ctx := context.TODO()
+	const category = "example.com::test"
+	const goSum = "golang.org/x/mod v0.1.0 h1:mod=\n"
+	srv := newGoProxyServer(t, map[string]string{
+		"/github.com/example/foo/@v/list":        "v1.0.0\n",
+		"/github.com/example/foo/@v/v1.0.0.info": `{"Version":"v1.0.0"}`,
+		"/github.com/example/foo/@v/v1.0.0.mod":  "module github.com/example/foo\n",
+		"/github.com/example/foo/@v/v1.0.0.zip":  moduleZip(t, "github.com/example/foo", "v1.0.0", map[string]string{"go.sum": goSum}),
+	})
+	sources := WithSources(staticSource{
+		{URL: "https://github.com/example/foo", Category: category},
+		{URL: "https://github.com/example/bar", Category: category},
+	})
+	c, err := New(WithDir(t.TempDir()), WithMode(ModeLocal), WithCategories(category), sources,
+		WithFetcher(FetcherGoProxy), WithGoProxy(&goproxy.Config{Proxies: []goproxy.Proxy{{URL: srv.URL}, {URL: goproxy.Off}}}))
+	assert.NilError(t, err)
+	assert.NilError(t, os.MkdirAll(c.LocalDir(), 0o755))
+	metas, err := c.Lookup(ctx, "h1:mod=")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(metas))
+
+	// The update aborts at example/bar, after storing the snapshot of example/foo.
+	assert.ErrorIs(t, c.Update(ctx), goproxy.ErrOff)
+	metas, err = c.Lookup(ctx, "h1:mod=")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(metas))
+	assert.Equal(t, "example/foo v1.0.0 (example.com::test)", metas[0].String())
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Explanation describes why a module version is (or is not) considered adopted
+// by a trusted project.
+type Explanation struct {
+	Module  string `json:"module"`
+	Version string `json:"version,omitempty"`
+	// Sum is the h1 sum of the module version that the hits are matched on.
+	Sum string `json:"sum,omitempty"`
+	// Hits lists the trusted-project snapshots whose go.sum has the module
+	// version with Sum, as [Cache.Lookup] does.
+	Hits []Meta `json:"hits"`
+	// Unmatched lists the trusted-project snapshots that use the module version
+	// without Sum in their go.sum: only vendored (vendor/modules.txt), or with
+	// another hash. They do not count as adoption.
+	Unmatched []Meta `json:"unmatched,omitempty"`
+	// OtherVersions lists the other versions of the module path that are used
+	// by trusted projects with a go.sum hash, newest first. It is only
+	// populated on a miss.
+	OtherVersions []VersionHits `json:"other_versions,omitempty"`
+}
+
+// VersionHits is a module version with the trusted-project snapshots using it.
+type VersionHits struct {
+	Version string `json:"version"`
+	Hits    []Meta `json:"hits"`
+}
+
+// Adopted reports whether the module version is adopted by a trusted project.
+func (e *Explanation) Adopted() bool {
+	return len(e.Hits) > 0
+}
+
+// Explain explains whether modPath@version, with the h1 sum, is adopted by a
+// trusted project. An empty sum stands for the sum of the version recorded in
+// the index. An empty version lists every adopted version of modPath as
+// OtherVersions.
+func (c *Cache) Explain(ctx context.Context, modPath, version, sum string) (*Explanation, error) {
+	idx, err := c.loadIndex(ctx, c.dataDir())
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{
+		Module:  modPath,
+		Version: version,
+		Sum:     sum,
+	}
+	versions := idx.Modules[modPath]
+	if mv := versions[version]; mv != nil {
+		if e.Sum == "" {
+			for _, me := range mv.Entries {
+				if me.Sum != "" {
+					e.Sum = me.Sum
+					break
+				}
+			}
+		}
+		if e.Sum != "" {
+			e.Hits = idx.lookup(e.Sum, c.trusts)
+		}
+		var unmatched []int
+		for _, me := range mv.Entries {
+			if me.Sum == "" || me.Sum != e.Sum {
+				unmatched = append(unmatched, me.Entry)
+			}
+		}
+		e.Unmatched = idx.metas(unmatched, c.trusts)
+	}
+	if e.Adopted() {
+		return e, nil
+	}
+	for v, mv := range versions {
+		if v == version {
+			continue
+		}
+		if hit := idx.metas(mv.summed(), c.trusts); len(hit) > 0 {
+			e.OtherVersions = append(e.OtherVersions, VersionHits{Version: v, Hits: hit})
+		}
+	}
+	sort.Slice(e.OtherVersions, func(i, j int) bool {
+		return newerVersion(e.OtherVersions[i].Version, e.OtherVersions[j].Version)
+	})
+	return e, nil
+}
+
+// WriteText writes a human-readable form of the explanation.
+func (e *Explanation) WriteText(w io.Writer) error {
+	name := e.Module
+	if e.Version != "" {
+		name += "@" + e.Version
+	}
+	if e.Sum != "" {
+		name += " (" + e.Sum + ")"
+	}
+	var b strings.Builder
+	switch {
+	case e.Adopted():
+		fmt.Fprintf(&b, "%s is adopted by %d trusted project snapshot(s):\n", name, len(e.Hits))
+		for _, m := range e.Hits {
+			fmt.Fprintf(&b, "  - %s\n", m)
+		}
+	case e.Version != "":
+		fmt.Fprintf(&b, "%s is not adopted by a trusted project\n", name)
+	}
+	if len(e.Unmatched) > 0 {
+		fmt.Fprintf(&b, "Used without a matching go.sum hash (vendored only, or another hash; not counted as adoption) by %d snapshot(s):\n",
+			len(e.Unmatched))
+		for _, m := range e.Unmatched {
+			fmt.Fprintf(&b, "  - %s\n", m)
+		}
+	}
+	if len(e.OtherVersions) > 0 {
+		if e.Version != "" {
+			fmt.Fprintf(&b, "Other versions of %s adopted by trusted projects:\n", e.Module)
+		} else {
+			fmt.Fprintf(&b, "Versions of %s adopted by trusted projects:\n", e.Module)
+		}
+		for _, vh := range e.OtherVersions {
+			ss := make([]string, len(vh.Hits))
+			for i, m := range vh.Hits {
+				ss[i] = m.String()
+			}
+			fmt.Fprintf(&b, "  - %s: %s\n", vh.Version, strings.Join(ss, ", "))
+		}
+	} else if !e.Adopted() {
+		fmt.Fprintf(&b, "No version of %s is adopted by a trusted project\n", e.Module)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,280 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/goproxy"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/progress"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
+// Fetcher selects how [ModeLocal] updates fetch the repositories.
+type Fetcher string
+
+const (
+	// FetcherForge fetches the tags and the files of the repositories from the
+	// forge APIs (e.g. api.github.com).
+	FetcherForge Fetcher = "forge"
+	// FetcherGoProxy fetches the versions and the files of the modules of the
+	// repositories from the Go module proxies ($GOPROXY).
+	// The repositories are fetched with [FetcherForge] when "direct" is reached
+	// in $GOPROXY, or when the modules match $GONOPROXY ($GOPRIVATE).
+	FetcherGoProxy Fetcher = "goproxy"
+)
+
+// ParseFetcher validates s and returns the corresponding [Fetcher].
+func ParseFetcher(s string) (Fetcher, error) {
+	f := Fetcher(s)
+	switch f {
+	case FetcherForge, FetcherGoProxy:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid fetcher %q (must be %q or %q)", s, FetcherForge, FetcherGoProxy)
+}
+
+// WithFetcher sets the [Fetcher] of [ModeLocal] updates. Defaults to [FetcherForge].
+func WithFetcher(fetcher Fetcher) Opt {
+	return func(opts *opts) error {
+		if fetcher == "" {
+			return nil
+		}
+		if _, err := ParseFetcher(string(fetcher)); err != nil {
+			return err
+		}
+		opts.fetcher = fetcher
+		return nil
+	}
+}
+
+// WithGoProxy sets the configuration of the module proxies of [FetcherGoProxy].
+// Defaults to [goproxy.FromEnv].
+func WithGoProxy(cfg *goproxy.Config) Opt {
+	return func(opts *opts) error {
+		opts.goProxy = cfg
+		return nil
+	}
+}
+
+// updateRepoGoProxy is the [FetcherGoProxy] counterpart of [Cache.updateRepo].
+//
+// The module path of each directory of r is assumed to be the host and the
+// path of the repository, e.g. "github.com/<OWNER>/<REPO>/<DIR>", optionally with
+// a major version suffix ("/v2", "/v3", ...). Modules with vanity import
+// paths (e.g. "k8s.io/kubernetes") are not found in the proxies, so
+// [goproxy.ErrDirect] is returned for them when $GOPROXY contains "direct".
+func (c *Cache) updateRepoGoProxy(ctx context.Context, r source.Repository) error {
+	repo, err := forge.ParseRepoURL(r.URL)
+	if err != nil {
+		return err
+	}
+	refs := r.Refs
+	if c.opts.defaultBranch {
+		refs.DefaultBranch = true
+	}
+	if refs.DefaultBranch || refs.BranchPattern != "" {
+		slog.WarnContext(ctx, "the module proxies do not serve branches; only the tags are indexed",
+			"repo", repo.String())
+	}
+	if r.DiscoverModules || c.opts.discoverModules {
+		slog.WarnContext(ctx, "the module proxies cannot discover nested modules; only the listed module directories are indexed",
+			"repo", repo.String())
+	}
+	root := path.Join(repo.Hostname(), repo.Owner, repo.Repo)
+	var mods []goProxyModule
+	for _, dir := range r.ModuleDirs() {
+		tags, err := c.goProxyTags(ctx, path.Join(root, dir))
+		if err != nil {
+			return err
+		}
+		if tags, err = selectTags(tags, refs); err != nil {
+			return fmt.Errorf("%s: %w", r.URL, err)
+		}
+		mods = append(mods, goProxyModule{Dir: dir, Tags: tags})
+	}
+	tagsDigest, err := digest(struct {
+		Repository source.Repository
+		Fetcher    Fetcher
+		Modules    []goProxyModule
+	}{r, FetcherGoProxy, mods})
+	if err != nil {
+		return err
+	}
+	// As with [Cache.updateRepo], the network access for the versions is
+	// skipped when they are unchanged since the last successful update, unless
+	// their snapshots have been removed since then.
+	versionsUnchanged := c.state != nil && c.state.repoUnchanged(r.URL, tagsDigest)
+	if versionsUnchanged {
+		c.onProgress(ctx, progress.Event{Message: fmt.Sprintf("%s: versions unchanged", repo)})
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	for _, mod := range mods {
+		var stored map[string]bool
+		if versionsUnchanged {
+			if stored, err = c.storedTagNames(*repo, mod.Dir); err != nil {
+				return err
+			}
+		}
+		for _, tag := range mod.Tags {
+			name := strings.TrimSuffix(tag.Name, "+incompatible")
+			if stored[name] || stored[path.Join(mod.Dir, name)] {
+				continue
+			}
+			g.Go(func() error {
+				return c.updateModuleGoProxy(gctx, *repo, r, mod.Dir, tag)
+			})
+		}
+	}
+	if err = g.Wait(); err != nil {
+		return err
+	}
+	if c.state != nil && !versionsUnchanged {
+		c.state.setRepo(r.URL, tagsDigest)
+	}
+	return nil
+}
+
+// storedTagNames returns the set of the tag names of the tag snapshots of the
+// module directory dir of repo, without accessing the network.
+// The snapshots of the module proxies are stored under the commits resolved
+// from the .info of the proxies, so they are found by the tag names instead of
+// [Cache.snapshotExists]. The names of the snapshots fetched from the forge
+// may have dir as the prefix (e.g. "<DIR>/<VERSION>").
+func (c *Cache) storedTagNames(repo forge.Repo, dir string) (map[string]bool, error) {
+	metaFiles, err := filepath.Glob(filepath.Join(c.repoDir(repo), "*", filepath.FromSlash(dir), MetaFilename))
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool)
+	for _, f := range metaFiles {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var meta Meta
+		if err = json.Unmarshal(b, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f, err)
+		}
+		if meta.Branch == nil && meta.Subpath == dir {
+			res[meta.Tag.Name] = true
+		}
+	}
+	return res, nil
+}
+
+// goProxyModule is a module directory of a repository, with the versions to
+// index.
+type goProxyModule struct {
+	Dir string
+	// Tags are the versions. See [Cache.goProxyTags].
+	Tags []forge.Tag
+}
+
+// goProxyTags returns the versions of the module at modPath, and of its major
+// version suffixes ("<modPath>/v2", "<modPath>/v3", ...), as tags for
+// [selectTags]. The Commit.SHA of each tag is "<MODULE>@<VERSION>" until
+// resolved by [Cache.updateModuleGoProxy].
+func (c *Cache) goProxyTags(ctx context.Context, modPath string) ([]forge.Tag, error) {
+	var (
+		tags    []forge.Tag
+		rootErr error
+	)
+	for major := 1; ; major++ {
+		p := modPath
+		if major >= 2 {
+			p = fmt.Sprintf("%s/v%d", modPath, major)
+		}
+		versions, err := c.goProxy.List(ctx, p, c.withValidators(c.goProxyHTTPOpts())...)
+		if err != nil {
+			notFound := goproxy.IsNotFound(err) || errors.Is(err, goproxy.ErrDirect)
+			if !notFound {
+				return nil, err
+			}
+			if major == 1 {
+				// The root may only have the major version suffixes, e.g., when
+				// its go.mod declares "<modPath>/v2".
+				rootErr = err
+				continue
+			}
+		}
+		if major >= 2 && len(versions) == 0 {
+			break
+		}
+		for _, v := range versions {
+			tags = append(tags, forge.Tag{Name: v, Commit: forge.Commit{SHA: p + "@" + v}})
+		}
+	}
+	if len(tags) == 0 {
+		if rootErr != nil {
+			return nil, rootErr
+		}
+		return nil, fmt.Errorf("%s: no versions found in the module proxies: %w", modPath, goproxy.ErrDirect)
+	}
+	return tags, nil
+}
+
+// updateModuleGoProxy fetches the go.mod, and the go.sum (and
+// vendor/modules.txt with [source.Repository.Vendor]) in the zip, of the
+// module version of tag, and stores them with [Cache.storeSnapshot].
+//
+// The snapshot is stored under the commit SHA recorded by the proxy (the
+// Origin of the .info), so that it is shared with [FetcherForge], or under
+// the version if the proxy does not record the origin.
+func (c *Cache) updateModuleGoProxy(ctx context.Context, repo forge.Repo, r source.Repository, dir string, tag forge.Tag) error {
+	modPath, version, _ := strings.Cut(tag.Commit.SHA, "@")
+	info, err := c.goProxy.Info(ctx, modPath, version, c.goProxyHTTPOpts()...)
+	if err != nil {
+		return err
+	}
+	commit := version
+	if info.Origin != nil && info.Origin.Hash != "" {
+		commit = info.Origin.Hash
+	}
+	meta := Meta{
+		Repo: repo,
+		// The tag of the module in a subdirectory is "<DIR>/<VERSION>" in the
+		// repository, but the subdirectory is already shown as the Subpath.
+		Tag:      forge.Tag{Name: strings.TrimSuffix(version, "+incompatible"), Commit: forge.Commit{SHA: commit, Time: info.Time}},
+		Subpath:  dir,
+		Category: r.Category,
+	}
+	if done, err := c.snapshotExists(meta); done || err != nil {
+		return err
+	}
+	mod, err := c.goProxy.Mod(ctx, modPath, version, c.goProxyHTTPOpts()...)
+	if err != nil {
+		return err
+	}
+	zipB, err := c.goProxy.Zip(ctx, modPath, version, c.goProxyHTTPOpts()...)
+	if err != nil {
+		return err
+	}
+	names := []string{"go.sum"}
+	if r.Vendor {
+		names = append(names, vendorModulesTxt)
+	}
+	zipFiles, err := goproxy.ZipFiles(zipB, modPath, version, names...)
+	if err != nil {
+		return fmt.Errorf("%s@%s: %w", modPath, version, err)
+	}
+	files := []snapshotFile{{path: "go.mod", data: mod}}
+	for _, name := range names {
+		b, ok := zipFiles[name]
+		if !ok {
+			// No dependencies, or not vendored
+			continue
+		}
+		files = append(files, snapshotFile{path: name, data: b})
+	}
+	return c.storeSnapshot(ctx, meta, files)
+}
@@ -0,0 +1,406 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/progress"
+)
+
+// indexDirName is the directory of the index files, next to the cache flavor
+// directories. The index files are kept out of the cache flavor directories,
+// so that the lookups do not modify them (e.g. their ModTime, or the worktree
+// of the remote cache).
+const indexDirName = "_index"
+
+// indexFile returns the index file of dataDir, e.g. "<DIR>/_index/local.json"
+// for [Cache.LocalDir].
+func (c *Cache) indexFile(dataDir string) string {
+	return filepath.Join(c.dir, indexDirName, strings.TrimPrefix(filepath.Base(dataDir), "_")+".json")
+}
+
+// indexVersion is bumped whenever the index format changes incompatibly, so
+// that stale indices are rebuilt rather than misread.
+const indexVersion = 4
+
+// index maps go.sum hashes to the cached trusted-project snapshots whose go.sum
+// contains them, so that [Cache.Lookup] is an in-process map lookup.
+type index struct {
+	Version int `json:"version"`
+	// Entries lists every indexed snapshot (a directory holding go.sum and
+	// [MetaFilename]).
+	Entries []indexEntry `json:"entries"`
+	// Sums maps a hash (e.g. "h1:...=") to indices into Entries.
+	Sums map[string][]int `json:"sums"`
+	// Modules maps a module path and version to the snapshots using it.
+	// Only versions with a go.sum line of the module content (not just the
+	// "/go.mod" line) are indexed, as the latter does not imply that the module
+	// is used.
+	Modules map[string]map[string]*moduleVersion `json:"modules"`
+}
+
+// moduleVersion is a module version used by indexed snapshots.
+type moduleVersion struct {
+	Entries []moduleEntry `json:"entries"`
+}
+
+// moduleEntry is a snapshot using a module version, with the hashes recorded in
+// the go.sum of that snapshot.
+type moduleEntry struct {
+	// Entry is an index into index.Entries.
+	Entry int `json:"entry"`
+	// Sum is empty when the version is only known from vendor/modules.txt.
+	Sum      string `json:"sum,omitempty"`
+	GoModSum string `json:"go_mod_sum,omitempty"`
+	// Direct is true when the go.mod of the snapshot requires the module
+	// without an "// indirect" comment.
+	Direct bool `json:"direct,omitempty"`
+}
+
+// entries returns the indices into index.Entries of the snapshots using the
+// module version.
+func (mv *moduleVersion) entries() []int {
+	res := make([]int, len(mv.Entries))
+	for i, me := range mv.Entries {
+		res[i] = me.Entry
+	}
+	return res
+}
+
+// summed returns the indices into index.Entries of the snapshots whose go.sum
+// records a hash of the module version. The snapshots that only vendor the
+// module version are skipped, as they do not count as adoption.
+func (mv *moduleVersion) summed() []int {
+	var res []int
+	for _, me := range mv.Entries {
+		if me.Sum != "" {
+			res = append(res, me.Entry)
+		}
+	}
+	return res
+}
+
+type indexEntry struct {
+	// Dir is relative to the cache flavor directory, slash-separated.
+	Dir  string `json:"dir"`
+	Meta Meta   `json:"meta"`
+}
+
+func (idx *index) lookup(sum string, keep func(Meta) bool) []Meta {
+	return idx.metas(idx.Sums[sum], keep)
+}
+
+// metas returns the metadata of entries, skipping those for which keep
+// returns false.
+func (idx *index) metas(entries []int, keep func(Meta) bool) []Meta {
+	var res []Meta
+	for _, i := range entries {
+		if m := idx.Entries[i].Meta; keep(m) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// buildIndex walks dataDir and indexes every go.sum that has a sibling
+// [MetaFilename].
+func buildIndex(dataDir string) (*index, error) {
+	idx := &index{
+		Version: indexVersion,
+		Sums:    make(map[string][]int),
+		Modules: make(map[string]map[string]*moduleVersion),
+	}
+	err := filepath.WalkDir(dataDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" || d.Name() == stagingDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "go.sum" {
+			return nil
+		}
+		dir := filepath.Dir(p)
+		metaB, err := os.ReadFile(filepath.Join(dir, MetaFilename))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				slog.Debug("skipping go.sum without meta", "path", p)
+				return nil
+			}
+			return err
+		}
+		var m Meta
+		if err = json.Unmarshal(metaB, &m); err != nil {
+			return fmt.Errorf("failed to parse %q: %w", filepath.Join(dir, MetaFilename), err)
+		}
+		rel, err := filepath.Rel(dataDir, dir)
+		if err != nil {
+			return err
+		}
+		lines, err := readGoSumLines(p)
+		if err != nil {
+			return err
+		}
+		direct := readDirectRequires(filepath.Join(dir, "go.mod"))
+		i := len(idx.Entries)
+		idx.Entries = append(idx.Entries, indexEntry{Dir: filepath.ToSlash(rel), Meta: m})
+		seen := make(map[string]struct{})
+		goModSums := make(map[module.Version]string)
+		for _, l := range lines {
+			if _, ok := seen[l.sum]; !ok && l.sum != "" {
+				seen[l.sum] = struct{}{}
+				idx.Sums[l.sum] = append(idx.Sums[l.sum], i)
+			}
+			if v, ok := strings.CutSuffix(l.version, "/go.mod"); ok {
+				goModSums[module.Version{Path: l.path, Version: v}] = l.sum
+			}
+		}
+		// Modules that are vendored but lack a go.sum line of the module content
+		// are indexed without a sum.
+		vendored, err := readVendorModules(filepath.Join(dir, filepath.FromSlash(vendorModulesTxt)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		summed := make(map[module.Version]bool, len(lines))
+		for _, l := range lines {
+			summed[module.Version{Path: l.path, Version: l.version}] = true
+		}
+		for _, v := range vendored {
+			if !summed[v] {
+				lines = append(lines, goSumLine{path: v.Path, version: v.Version})
+			}
+		}
+		for _, l := range lines {
+			if strings.HasSuffix(l.version, "/go.mod") {
+				continue
+			}
+			versions := idx.Modules[l.path]
+			if versions == nil {
+				versions = make(map[string]*moduleVersion)
+				idx.Modules[l.path] = versions
+			}
+			mv := versions[l.version]
+			if mv == nil {
+				mv = &moduleVersion{}
+				versions[l.version] = mv
+			}
+			if n := len(mv.Entries); n > 0 && mv.Entries[n-1].Entry == i {
+				continue
+			}
+			mod := module.Version{Path: l.path, Version: l.version}
+			_, isDirect := direct[mod]
+			mv.Entries = append(mv.Entries, moduleEntry{
+				Entry:    i,
+				Sum:      l.sum,
+				GoModSum: goModSums[mod],
+				Direct:   isDirect,
+			})
+		}
+		return nil
+	})
+	return idx, err
+}
+
+// readDirectRequires returns the modules required by goModFile without an
+// "// indirect" comment. A missing or malformed go.mod yields none, as the
+// go.sum alone is enough for the other index queries.
+func readDirectRequires(goModFile string) map[module.Version]struct{} {
+	res := make(map[module.Version]struct{})
+	b, err := os.ReadFile(goModFile)
+	if err != nil {
+		slog.Debug("failed to read go.mod", "path", goModFile, "error", err)
+		return res
+	}
+	goMod, err := modfile.ParseLax(goModFile, b, nil)
+	if err != nil {
+		slog.Debug("failed to parse go.mod", "path", goModFile, "error", err)
+		return res
+	}
+	for _, req := range goMod.Require {
+		if !req.Indirect {
+			res[req.Mod] = struct{}{}
+		}
+	}
+	return res
+}
+
+// vendorModulesTxt is the slash-separated path of the vendored module list,
+// relative to the go.mod directory.
+const vendorModulesTxt = "vendor/modules.txt"
+
+// readVendorModules parses the "# <PATH> <VERSION> [=> <NEWPATH> <NEWVERSION>]"
+// lines of vendor/modules.txt. For replaced modules, the replacement is
+// returned, unless it is a local directory.
+func readVendorModules(modulesTxt string) ([]module.Version, error) {
+	f, err := os.Open(modulesTxt)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res []module.Version
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, ok := strings.CutPrefix(sc.Text(), "# ")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(line)
+		var mod module.Version
+		switch {
+		case len(fields) == 2:
+			mod = module.Version{Path: fields[0], Version: fields[1]}
+		case len(fields) == 5 && fields[2] == "=>":
+			mod = module.Version{Path: fields[3], Version: fields[4]}
+		default:
+			// e.g. "# example.com/foo => ../foo"
+			continue
+		}
+		if !slices.Contains(res, mod) {
+			res = append(res, mod)
+		}
+	}
+	return res, sc.Err()
+}
+
+type goSumLine struct {
+	path    string
+	version string // may have the "/go.mod" suffix
+	sum     string
+}
+
+// readGoSumLines parses a go.sum file, skipping malformed lines.
+func readGoSumLines(goSumFile string) ([]goSumLine, error) {
+	f, err := os.Open(goSumFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res []goSumLine
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		res = append(res, goSumLine{path: fields[0], version: fields[1], sum: fields[2]})
+	}
+	return res, sc.Err()
+}
+
+func readIndex(indexFile string) (*index, error) {
+	b, err := os.ReadFile(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	var idx index
+	if err = json.Unmarshal(b, &idx); err != nil {
+		return nil, err
+	}
+	if idx.Version != indexVersion {
+		return nil, fmt.Errorf("%w: unsupported index version %d", fs.ErrNotExist, idx.Version)
+	}
+	return &idx, nil
+}
+
+// writeIndex atomically writes idx to indexFile.
+func writeIndex(indexFile string, idx *index) error {
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(indexFile), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(indexFile), filepath.Base(indexFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(b); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, indexFile)
+}
+
+// reindex rebuilds and persists the index of dataDir, replacing the in-memory copy.
+func (c *Cache) reindex(ctx context.Context, dataDir string) error {
+	c.onProgress(ctx, progress.Event{Message: "indexing " + dataDir})
+	idx, err := buildIndex(dataDir)
+	if err != nil {
+		return err
+	}
+	if err = writeIndex(c.indexFile(dataDir), idx); err != nil {
+		return err
+	}
+	c.idxMu.Lock()
+	if c.idx == nil {
+		c.idx = make(map[string]*index)
+	}
+	c.idx[dataDir] = idx
+	c.idxMu.Unlock()
+	return nil
+}
+
+// invalidateIndex removes the index of dataDir, so that [Cache.loadIndex]
+// rebuilds it on the next lookup.
+func (c *Cache) invalidateIndex(dataDir string) error {
+	c.idxMu.Lock()
+	delete(c.idx, dataDir)
+	c.idxMu.Unlock()
+	if err := os.Remove(c.indexFile(dataDir)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// loadIndex returns the index of dataDir, reading it once per [Cache]. A missing
+// or outdated index (e.g. a cache populated by an older release) is rebuilt.
+func (c *Cache) loadIndex(ctx context.Context, dataDir string) (*index, error) {
+	c.idxMu.Lock()
+	idx, ok := c.idx[dataDir]
+	c.idxMu.Unlock()
+	if ok {
+		return idx, nil
+	}
+	idx, err := readIndex(c.indexFile(dataDir))
+	if err == nil {
+		c.idxMu.Lock()
+		if c.idx == nil {
+			c.idx = make(map[string]*index)
+		}
+		c.idx[dataDir] = idx
+		c.idxMu.Unlock()
+		return idx, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		slog.WarnContext(ctx, "failed to read the cache index; rebuilding", "dir", dataDir, "error", err)
+	}
+	if err = c.reindex(ctx, dataDir); err != nil {
+		return nil, err
+	}
+	c.idxMu.Lock()
+	idx = c.idx[dataDir]
+	c.idxMu.Unlock()
+	return idx, nil
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"golang.org/x/mod/semver"
+)
+
+// Record is a module version used by a trusted-project snapshot, as recorded
+// in the go.sum and go.mod of the snapshot.
+type Record struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// Sum is the h1 hash of the module content. It is empty when the version is
+	// only known from the vendor/modules.txt of the snapshot.
+	Sum string `json:"sum,omitempty"`
+	// GoModSum is the h1 hash of the go.mod file of the module, if recorded.
+	GoModSum string `json:"go_mod_sum,omitempty"`
+	// Direct is true when the go.mod of the snapshot requires the module
+	// without an "// indirect" comment.
+	Direct bool `json:"direct"`
+	Meta   Meta `json:"meta"`
+}
+
+// newerVersion orders versions newest first by semver, falling back to the
+// string order for invalid versions so that the order is stable.
+func newerVersion(a, b string) bool {
+	if c := semver.Compare(a, b); c != 0 {
+		return c > 0
+	}
+	return a > b
+}
+
+// LookupPath returns the records of every version of modPath used by trusted
+// projects, newest version first. Like the other lookups, only snapshots of the
+// trusted categories ([WithCategories]) are considered.
+func (c *Cache) LookupPath(ctx context.Context, modPath string) ([]Record, error) {
+	return c.LookupRange(ctx, modPath, "", "")
+}
+
+// LookupRange returns the records of the versions of modPath in the inclusive
+// range [minVersion, maxVersion] used by trusted projects, newest version
+// first. An empty bound leaves that side of the range open.
+func (c *Cache) LookupRange(ctx context.Context, modPath, minVersion, maxVersion string) ([]Record, error) {
+	for _, v := range []string{minVersion, maxVersion} {
+		if v != "" && !semver.IsValid(v) {
+			return nil, fmt.Errorf("invalid version %q", v)
+		}
+	}
+	idx, err := c.loadIndex(ctx, c.dataDir())
+	if err != nil {
+		return nil, err
+	}
+	versions := idx.Modules[modPath]
+	keys := make([]string, 0, len(versions))
+	for v := range versions {
+		if minVersion != "" && semver.Compare(v, minVersion) < 0 {
+			continue
+		}
+		if maxVersion != "" && semver.Compare(v, maxVersion) > 0 {
+			continue
+		}
+		keys = append(keys, v)
+	}
+	sort.Slice(keys, func(i, j int) bool { return newerVersion(keys[i], keys[j]) })
+	var res []Record
+	for _, v := range keys {
+		mv := versions[v]
+		for _, me := range mv.Entries {
+			m := idx.Entries[me.Entry].Meta
+			if !c.trusts(m) {
+				continue
+			}
+			res = append(res, Record{
+				Path:     modPath,
+				Version:  v,
+				Sum:      me.Sum,
+				GoModSum: me.GoModSum,
+				Direct:   me.Direct,
+				Meta:     m,
+			})
+		}
+	}
+	return res, nil
+}
+
+// Latest returns the newest version of modPath used by a trusted project, or
+// the empty string if none is.
+func (c *Cache) Latest(ctx context.Context, modPath string) (string, error) {
+	idx, err := c.loadIndex(ctx, c.dataDir())
+	if err != nil {
+		return "", err
+	}
+	var latest string
+	for v, mv := range idx.Modules[modPath] {
+		if len(idx.metas(mv.entries(), c.trusts)) == 0 {
+			continue
+		}
+		if latest == "" || newerVersion(v, latest) {
+			latest = v
+		}
+	}
+	return latest, nil
+}
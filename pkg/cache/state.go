@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+)
+
+// stateDirName is the directory of the state of [Cache.Update], next to the
+// cache flavor directories. Like the index files, the state is kept out of
+// [Cache.LocalDir], whose ModTime is the time of the last successful update
+// (see [Cache.ReadMode]).
+const stateDirName = "_state"
+
+// stateFile returns the file that persists the state of the local updates for
+// the next incremental update, i.e., "<DIR>/_state/local.json".
+func (c *Cache) stateFile() string {
+	return filepath.Join(c.dir, stateDirName, strings.TrimPrefix(localDirName, "_")+".json")
+}
+
+// legacyStateFilename is the state file in [Cache.LocalDir] written by older
+// releases. It is removed on the next successful update.
+const legacyStateFilename = "gosocialcheck-state.json"
+
+// stateVersion is bumped whenever the schema of [state] changes.
+const stateVersion = 1
+
+// state is the state of the local cache updates.
+// state implements [netutil.ValidatorStore].
+type state struct {
+	mu sync.Mutex
+	stateJSON
+	// used is the set of the URLs of the validators loaded or stored during
+	// the current update.
+	used map[string]struct{}
+	// source is the name of the source being updated.
+	source string
+}
+
+type stateJSON struct {
+	Version int `json:"version"`
+	// Validators are the validators of the responses of the source listings
+	// and the GitHub API, keyed by URL.
+	Validators map[string]*netutil.Validator `json:"validators"`
+	// Sources maps the URLs of Validators to the names of the sources that
+	// requested them.
+	Sources map[string]string `json:"sources,omitempty"`
+	// Repos are the digests of the refs indexed on the last successful update,
+	// keyed by the repository URL.
+	Repos map[string]string `json:"repos"`
+}
+
+func (s *state) LoadValidator(urlStr string) (*netutil.Validator, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.use(urlStr)
+	v, ok := s.Validators[urlStr]
+	return v, ok
+}
+
+func (s *state) StoreValidator(urlStr string, v *netutil.Validator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.use(urlStr)
+	s.Validators[urlStr] = v
+}
+
+// use marks urlStr as requested by the current source. s.mu must be held.
+func (s *state) use(urlStr string) {
+	s.used[urlStr] = struct{}{}
+	if s.source != "" {
+		s.Sources[urlStr] = s.source
+	}
+}
+
+// setSource sets the name of the source being updated, recorded along with the
+// validators requested from now on.
+func (s *state) setSource(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.source = name
+}
+
+// pruneValidators drops the validators of the URLs that were not requested
+// during the current update, e.g. of the repositories removed from the sources,
+// so that the state does not grow indefinitely. The validators of the
+// failedSources are kept, as their repositories were not visited.
+func (s *state) pruneValidators(failedSources []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for urlStr := range s.Validators {
+		if _, ok := s.used[urlStr]; ok {
+			continue
+		}
+		if slices.Contains(failedSources, s.Sources[urlStr]) {
+			continue
+		}
+		delete(s.Validators, urlStr)
+		delete(s.Sources, urlStr)
+	}
+	for urlStr := range s.Sources {
+		if _, ok := s.Validators[urlStr]; !ok {
+			delete(s.Sources, urlStr)
+		}
+	}
+}
+
+// repoUnchanged reports whether the digest of the repository is the same as
+// the last successful update.
+func (s *state) repoUnchanged(repoURL, digest string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Repos[repoURL] == digest
+}
+
+func (s *state) setRepo(repoURL, digest string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Repos[repoURL] = digest
+}
+
+// digest returns the digest of the JSON of x.
+func digest(x any) (string, error) {
+	b, err := json.Marshal(x)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readState reads the state from stateFile. An empty state is returned when
+// the file does not exist or has an outdated version.
+func readState(stateFile string) (*state, error) {
+	s := &state{
+		stateJSON: stateJSON{
+			Version:    stateVersion,
+			Validators: make(map[string]*netutil.Validator),
+			Sources:    make(map[string]string),
+			Repos:      make(map[string]string),
+		},
+		used: make(map[string]struct{}),
+	}
+	b, err := os.ReadFile(stateFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	var sj stateJSON
+	if err = json.Unmarshal(b, &sj); err != nil {
+		return nil, err
+	}
+	if sj.Version != stateVersion {
+		return s, nil
+	}
+	if sj.Validators != nil {
+		s.Validators = sj.Validators
+	}
+	if sj.Sources != nil {
+		s.Sources = sj.Sources
+	}
+	if sj.Repos != nil {
+		s.Repos = sj.Repos
+	}
+	return s, nil
+}
+
+// writeState writes the state to stateFile atomically.
+func writeState(stateFile string, s *state) error {
+	s.mu.Lock()
+	b, err := json.Marshal(s.stateJSON)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(stateFile), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(stateFile), filepath.Base(stateFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(b); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, stateFile)
+}
@@ -1,6 +1,50 @@
 package categories
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
 const (
-	CNCFGraduated    = "cncf.io::graduated"
+	CNCFGraduated = "cncf.io::graduated"
+	// CNCFGraduatedSub is for the secondary ("code-lite") repositories of CNCF
+	// Graduated projects, and the other repositories in the same org.
 	CNCFGraduatedSub = "cncf.io::graduated::sub"
+	CNCFIncubating   = "cncf.io::incubating"
+	CNCFSandbox      = "cncf.io::sandbox"
+	// ASFTLP is for the Apache Software Foundation top-level projects.
+	ASFTLP = "apache.org::tlp"
+	// GoToolchain is for the Go distribution and the golang.org/x repositories.
+	GoToolchain = "go.dev::toolchain"
 )
+
+// Default is the set of categories trusted unless configured otherwise.
+var Default = []string{CNCFGraduated}
+
+// All lists the known categories, from the most to the least trusted.
+var All = []string{CNCFGraduated, CNCFGraduatedSub, CNCFIncubating, CNCFSandbox, ASFTLP, GoToolchain}
+
+// Parse parses a comma-separated list of categories, e.g.
+// "cncf.io::graduated,cncf.io::incubating". Categories other than [All] and
+// extra (e.g. user-defined categories) are rejected.
+func Parse(s string, extra ...string) ([]string, error) {
+	var res []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !slices.Contains(All, f) && !slices.Contains(extra, f) {
+			known := append(slices.Clone(All), extra...)
+			return nil, fmt.Errorf("unknown category %q (must be one of %s)", f, strings.Join(known, ", "))
+		}
+		if !slices.Contains(res, f) {
+			res = append(res, f)
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no category specified in %q", s)
+	}
+	return res, nil
+}
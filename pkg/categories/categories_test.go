@@ -0,0 +1,19 @@
+package categories
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse("cncf.io::graduated, cncf.io::sandbox,cncf.io::graduated")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{CNCFGraduated, CNCFSandbox}, got)
+
+	_, err = Parse("cncf.io::graduated,example.com::foo")
+	assert.ErrorContains(t, err, `unknown category "example.com::foo"`)
+
+	_, err = Parse(",")
+	assert.ErrorContains(t, err, "no category")
+}
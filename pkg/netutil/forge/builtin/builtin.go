@@ -0,0 +1,9 @@
+// Package builtin links in the built-in forges.
+package builtin
+
+import (
+	// Each package registers its forge with forge.Register.
+	_ "github.com/AkihiroSuda/gosocialcheck/pkg/netutil/gitea"
+	_ "github.com/AkihiroSuda/gosocialcheck/pkg/netutil/github"
+	_ "github.com/AkihiroSuda/gosocialcheck/pkg/netutil/gitlab"
+)
@@ -0,0 +1,280 @@
+// Package forge abstracts the APIs of the git hosting services ("forges"),
+// such as GitHub, GitLab, and Gitea (including Forgejo and Codeberg).
+//
+// The implementations are registered by the packages under pkg/netutil,
+// and linked in by importing [github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge/builtin].
+package forge
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+)
+
+// Kind is the kind of a forge.
+type Kind string
+
+const (
+	GitHub Kind = "github"
+	GitLab Kind = "gitlab"
+	// Gitea also covers Forgejo (e.g., Codeberg), which implements the Gitea API.
+	Gitea Kind = "gitea"
+)
+
+// DefaultMaxTagPages is the recommended maxPages for [Client.Tags] and
+// [Client.Branches].
+const DefaultMaxTagPages = 10
+
+// WarnTruncated logs that the listing (e.g. "tags") of repo stopped at
+// maxPages pages. The tags are not listed in the order of the versions, so
+// the selection of the tags may miss some release lines.
+func WarnTruncated(ctx context.Context, repo Repo, listing string, maxPages int) {
+	slog.WarnContext(ctx, "the listing is truncated at the maximum number of the pages; some release lines may be missing",
+		"repo", repo.String(), "listing", listing, "maxPages", maxPages)
+}
+
+// Repo identifies a repository on a forge.
+// The zero values of Forge and Host are treated as "github" and "github.com",
+// for compatibility with the caches written before the other forges were supported.
+type Repo struct {
+	Forge Kind   `json:"forge,omitempty"`
+	Host  string `json:"host,omitempty"`
+	// Owner may contain slashes for GitLab subgroups, e.g. "gitlab-org/cli".
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+}
+
+// Kind returns the kind of the forge of r.
+func (r Repo) Kind() Kind {
+	if r.Forge == "" {
+		return GitHub
+	}
+	return r.Forge
+}
+
+// Hostname returns the host of r, e.g. "github.com".
+func (r Repo) Hostname() string {
+	if r.Host == "" {
+		return "github.com"
+	}
+	return r.Host
+}
+
+// String returns "<OWNER>/<REPO>" for github.com, "<HOST>/<OWNER>/<REPO>" otherwise.
+func (r Repo) String() string {
+	if r.Hostname() == "github.com" {
+		return path.Join(r.Owner, r.Repo)
+	}
+	return path.Join(r.Hostname(), r.Owner, r.Repo)
+}
+
+// Commit is a git commit.
+type Commit struct {
+	SHA string `json:"sha"`
+	// Time is the committer date of the commit, if known.
+	Time time.Time `json:"time,omitzero"`
+}
+
+// Tag is a git tag.
+type Tag struct {
+	Name   string `json:"name"`
+	Commit Commit `json:"commit"`
+}
+
+// Branch is the tip of a branch.
+type Branch struct {
+	Name   string `json:"name"`
+	Commit Commit `json:"commit"`
+}
+
+// TreeEntry is an entry of a git tree.
+type TreeEntry struct {
+	// Path is slash-separated, relative to the repository root.
+	Path string `json:"path"`
+	// Type is "blob", "tree", or "commit" (submodule).
+	Type string `json:"type"`
+}
+
+// Client is the API client of a forge, for a repository.
+type Client interface {
+	// Tags returns the tags, in the order returned by the API (not sorted by
+	// version). At most maxPages pages are fetched, with [WarnTruncated] when
+	// more pages may follow; zero or a negative maxPages means all the pages.
+	Tags(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]Tag, error)
+	// DefaultBranch returns the name of the default branch, e.g. "main".
+	DefaultBranch(ctx context.Context, o ...netutil.HTTPOpt) (string, error)
+	// Branches returns the names of the branches. See Tags for maxPages.
+	Branches(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]string, error)
+	// Branch returns the tip of the branch.
+	Branch(ctx context.Context, name string, o ...netutil.HTTPOpt) (*Branch, error)
+	// Tree returns the entries of the tree of the commit, recursively.
+	// truncated reports whether the listing is incomplete.
+	Tree(ctx context.Context, commit string, o ...netutil.HTTPOpt) (entries []TreeEntry, truncated bool, err error)
+	// ContentURL returns the URL of the raw content of the file p at the commit.
+	ContentURL(commit, p string) string
+}
+
+// OwnerLister is an optional extension of [Client] that lists the other
+// repositories of the owner (user or organization) of the repository.
+type OwnerLister interface {
+	// OwnerRepos returns the Go repositories of the owner, skipping archived
+	// repositories and forks. See [Client.Tags] for maxPages.
+	OwnerRepos(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]Repo, error)
+}
+
+// NewClientFunc instantiates the [Client] of repo.
+type NewClientFunc func(repo Repo) (Client, error)
+
+// Host is the configuration of a forge host.
+type Host struct {
+	Kind Kind `yaml:"type" json:"type"`
+	// APIURL is the base URL of the API, e.g. "https://github.example.com/api/v3".
+	// Defaults to the endpoint of the forge on the host.
+	APIURL string `yaml:"api_url,omitempty" json:"api_url,omitempty"`
+	// RawURL is the base URL of the raw contents, e.g. "https://github.example.com/raw".
+	// Defaults to the endpoint of the forge on the host.
+	RawURL string `yaml:"raw_url,omitempty" json:"raw_url,omitempty"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[Kind]NewClientFunc)
+	hosts      = map[string]Host{
+		"github.com":   {Kind: GitHub},
+		"gitlab.com":   {Kind: GitLab},
+		"codeberg.org": {Kind: Gitea},
+		"gitea.com":    {Kind: Gitea},
+	}
+)
+
+// Register registers the implementation of a forge.
+// Register is typically called from the init function of the implementation.
+// Register panics if the kind is already registered.
+func Register(kind Kind, f NewClientFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[kind]; ok {
+		panic(fmt.Errorf("forge %q is already registered", kind))
+	}
+	registry[kind] = f
+}
+
+// RegisterHost registers a forge host, e.g. a GitHub Enterprise Server or a
+// self-hosted GitLab.
+func RegisterHost(name string, h Host) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	hosts[strings.ToLower(name)] = h
+}
+
+// LookupHost returns the configuration of the forge host.
+func LookupHost(name string) (Host, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	h, ok := hosts[strings.ToLower(name)]
+	return h, ok
+}
+
+// Validate validates h.
+func (h Host) Validate() error {
+	switch h.Kind {
+	case GitHub, GitLab, Gitea:
+	default:
+		return fmt.Errorf("unknown forge type %q (must be %q, %q, or %q)", h.Kind, GitHub, GitLab, Gitea)
+	}
+	for _, s := range []string{h.APIURL, h.RawURL} {
+		if s == "" {
+			continue
+		}
+		if u, err := url.Parse(s); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid URL %q", s)
+		}
+	}
+	return nil
+}
+
+// ParseRepoURL parses a repository URL such as "https://github.com/<OWNER>/<REPO>",
+// "https://gitlab.com/<GROUP>/<SUBGROUP>/<REPO>", or "https://codeberg.org/<OWNER>/<REPO>".
+// The host has to be registered (see [RegisterHost]).
+func ParseRepoURL(urlStr string) (*Repo, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	h, ok := LookupHost(host)
+	if !ok {
+		return nil, fmt.Errorf("invalid repo URL: %q: unknown forge host %q", urlStr, host)
+	}
+	return ParseRepoURLOfKind(urlStr, h.Kind)
+}
+
+// ParseRepoURLOfKind is similar to [ParseRepoURL] but does not look up the host.
+func ParseRepoURLOfKind(urlStr string, kind Kind) (*Repo, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid repo URL: %q", urlStr)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	p := strings.Trim(u.Path, "/")
+	var elems []string
+	switch kind {
+	case GitLab:
+		// "https://gitlab.com/<GROUP>/<SUBGROUP>/<REPO>/-/tree/main"
+		p, _, _ = strings.Cut(p, "/-/")
+		elems = strings.Split(p, "/")
+	default:
+		// "https://github.com/<OWNER>/<REPO>/tree/main"
+		elems = strings.SplitN(p, "/", 3)
+		if len(elems) == 3 {
+			elems = elems[:2]
+		}
+	}
+	if len(elems) < 2 || slices.Contains(elems, "") {
+		return nil, fmt.Errorf("invalid repo URL: %q", urlStr)
+	}
+	repo := &Repo{
+		Forge: kind,
+		Host:  host,
+		Owner: strings.Join(elems[:len(elems)-1], "/"),
+		Repo:  strings.TrimSuffix(elems[len(elems)-1], ".git"),
+	}
+	return repo, nil
+}
+
+// Endpoints returns the base URLs of the API and the raw contents of repo,
+// as registered with [RegisterHost], or the given defaults.
+func Endpoints(repo Repo, defaultAPIURL, defaultRawURL string) (apiURL, rawURL string) {
+	apiURL, rawURL = defaultAPIURL, defaultRawURL
+	if h, ok := LookupHost(repo.Hostname()); ok {
+		if h.APIURL != "" {
+			apiURL = strings.TrimSuffix(h.APIURL, "/")
+		}
+		if h.RawURL != "" {
+			rawURL = strings.TrimSuffix(h.RawURL, "/")
+		}
+	}
+	return apiURL, rawURL
+}
+
+// NewClient instantiates the [Client] of repo.
+func NewClient(repo Repo) (Client, error) {
+	registryMu.RLock()
+	f, ok := registry[repo.Kind()]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported forge %q (%s)", repo.Kind(), repo)
+	}
+	return f(repo)
+}
@@ -0,0 +1,66 @@
+package forge
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseRepoURL(t *testing.T) {
+	cases := []struct {
+		url      string
+		expected *Repo
+	}{
+		{"https://github.com/containerd/nerdctl", &Repo{Forge: GitHub, Host: "github.com", Owner: "containerd", Repo: "nerdctl"}},
+		{"http://www.github.com/containerd/nerdctl.git", &Repo{Forge: GitHub, Host: "github.com", Owner: "containerd", Repo: "nerdctl"}},
+		{"https://github.com/containerd/nerdctl/", &Repo{Forge: GitHub, Host: "github.com", Owner: "containerd", Repo: "nerdctl"}},
+		{"https://github.com/containerd/nerdctl.git/", &Repo{Forge: GitHub, Host: "github.com", Owner: "containerd", Repo: "nerdctl"}},
+		{"https://github.com/containerd/nerdctl/tree/main/pkg", &Repo{Forge: GitHub, Host: "github.com", Owner: "containerd", Repo: "nerdctl"}},
+		{"https://gitlab.com/gitlab-org/cli", &Repo{Forge: GitLab, Host: "gitlab.com", Owner: "gitlab-org", Repo: "cli"}},
+		{"https://gitlab.com/gitlab-org/api/client-go/-/tree/main", &Repo{Forge: GitLab, Host: "gitlab.com", Owner: "gitlab-org/api", Repo: "client-go"}},
+		{"https://codeberg.org/forgejo/forgejo.git", &Repo{Forge: Gitea, Host: "codeberg.org", Owner: "forgejo", Repo: "forgejo"}},
+		{"https://github.com/containerd", nil},
+		{"https://github.com/containerd/", nil},
+		{"https://example.com/foo/bar", nil},
+		{"https://github.com.example.com/containerd/nerdctl", nil},
+		{"ssh://github.com/foo/bar", nil},
+	}
+	for _, tc := range cases {
+		got, err := ParseRepoURL(tc.url)
+		if tc.expected != nil {
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.expected, got)
+		} else {
+			assert.ErrorContains(t, err, "invalid")
+		}
+	}
+
+	RegisterHost("git.example.com", Host{Kind: GitLab})
+	got, err := ParseRepoURL("https://git.example.com/foo/bar")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Repo{Forge: GitLab, Host: "git.example.com", Owner: "foo", Repo: "bar"}, got)
+}
+
+func TestEndpoints(t *testing.T) {
+	repo := Repo{Forge: GitHub, Host: "ghe.example.com", Owner: "foo", Repo: "bar"}
+	apiURL, rawURL := Endpoints(repo, "https://ghe.example.com/api/v3", "https://ghe.example.com/raw")
+	assert.Equal(t, "https://ghe.example.com/api/v3", apiURL)
+	assert.Equal(t, "https://ghe.example.com/raw", rawURL)
+
+	RegisterHost("ghe.example.com", Host{Kind: GitHub, APIURL: "https://api.ghe.example.com/", RawURL: "https://raw.ghe.example.com"})
+	apiURL, rawURL = Endpoints(repo, "https://ghe.example.com/api/v3", "https://ghe.example.com/raw")
+	assert.Equal(t, "https://api.ghe.example.com", apiURL)
+	assert.Equal(t, "https://raw.ghe.example.com", rawURL)
+}
+
+func TestHostValidate(t *testing.T) {
+	assert.NilError(t, Host{Kind: Gitea}.Validate())
+	assert.ErrorContains(t, Host{Kind: "svn"}.Validate(), "unknown forge type")
+	assert.ErrorContains(t, Host{Kind: GitHub, APIURL: "ghe.example.com/api/v3"}.Validate(), "invalid URL")
+}
+
+func TestRepoString(t *testing.T) {
+	assert.Equal(t, "containerd/containerd", Repo{Owner: "containerd", Repo: "containerd"}.String())
+	assert.Equal(t, "containerd/containerd", Repo{Forge: GitHub, Host: "github.com", Owner: "containerd", Repo: "containerd"}.String())
+	assert.Equal(t, "gitlab.com/gitlab-org/api/client-go", Repo{Forge: GitLab, Host: "gitlab.com", Owner: "gitlab-org/api", Repo: "client-go"}.String())
+}
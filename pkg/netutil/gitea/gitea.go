@@ -0,0 +1,188 @@
+// Package gitea implements the [forge.Client] of Gitea and Forgejo (e.g., Codeberg).
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+)
+
+func init() {
+	forge.Register(forge.Gitea, func(repo forge.Repo) (forge.Client, error) {
+		return New(repo), nil
+	})
+}
+
+// New instantiates the [Client] of repo.
+// The endpoints can be configured with [forge.RegisterHost].
+func New(repo forge.Repo) *Client {
+	base := "https://" + repo.Hostname()
+	c := &Client{Repo: repo}
+	c.APIURL, c.RawURL = forge.Endpoints(repo, base+"/api/v1", base)
+	return c
+}
+
+// Client is the [forge.Client] of Gitea (API v1).
+type Client struct {
+	Repo forge.Repo
+	// APIURL is the base URL of the API, e.g. "https://codeberg.org/api/v1".
+	APIURL string
+	// RawURL is the base URL of the raw contents, e.g. "https://codeberg.org".
+	RawURL string
+}
+
+var _ forge.Client = (*Client)(nil)
+
+// httpOpts appends the token of the forge host to o, for the API served on
+// another host (see [netutil.WithHostToken]).
+func (c *Client) httpOpts(o []netutil.HTTPOpt) []netutil.HTTPOpt {
+	return append(slices.Clip(o), netutil.WithHostToken(c.Repo.Hostname()))
+}
+
+// PerPage is the number of the items fetched per request
+// (the default maximum of the Gitea API).
+const PerPage = 50
+
+// maxTreePages is the maximum number of the pages of [Client.Tree].
+const maxTreePages = 100
+
+func (c *Client) repoURL() string {
+	return fmt.Sprintf("%s/repos/%s/%s", c.APIURL, url.PathEscape(c.Repo.Owner), url.PathEscape(c.Repo.Repo))
+}
+
+type commit struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (c *Client) Tags(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]forge.Tag, error) {
+	var res []forge.Tag
+	for page := 1; ; page++ {
+		if maxPages > 0 && page > maxPages {
+			forge.WarnTruncated(ctx, c.Repo, "tags", maxPages)
+			break
+		}
+		b, err := netutil.Get(ctx, fmt.Sprintf("%s/tags?limit=%d&page=%d", c.repoURL(), PerPage, page), c.httpOpts(o)...)
+		if err != nil {
+			return res, err
+		}
+		var tags []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				SHA string `json:"sha"`
+			} `json:"commit"`
+		}
+		if err = json.Unmarshal(b, &tags); err != nil {
+			return res, err
+		}
+		for _, t := range tags {
+			res = append(res, forge.Tag{Name: t.Name, Commit: forge.Commit{SHA: t.Commit.SHA}})
+		}
+		if len(tags) < PerPage {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) DefaultBranch(ctx context.Context, o ...netutil.HTTPOpt) (string, error) {
+	b, err := netutil.Get(ctx, c.repoURL(), c.httpOpts(o)...)
+	if err != nil {
+		return "", err
+	}
+	var info struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err = json.Unmarshal(b, &info); err != nil {
+		return "", err
+	}
+	if info.DefaultBranch == "" {
+		return "", fmt.Errorf("%s: no default branch", c.Repo)
+	}
+	return info.DefaultBranch, nil
+}
+
+func (c *Client) Branches(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]string, error) {
+	var res []string
+	for page := 1; ; page++ {
+		if maxPages > 0 && page > maxPages {
+			forge.WarnTruncated(ctx, c.Repo, "branches", maxPages)
+			break
+		}
+		b, err := netutil.Get(ctx, fmt.Sprintf("%s/branches?limit=%d&page=%d", c.repoURL(), PerPage, page), c.httpOpts(o)...)
+		if err != nil {
+			return res, err
+		}
+		var branches []struct {
+			Name string `json:"name"`
+		}
+		if err = json.Unmarshal(b, &branches); err != nil {
+			return res, err
+		}
+		for _, br := range branches {
+			res = append(res, br.Name)
+		}
+		if len(branches) < PerPage {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) Branch(ctx context.Context, name string, o ...netutil.HTTPOpt) (*forge.Branch, error) {
+	b, err := netutil.Get(ctx, c.repoURL()+"/branches/"+url.PathEscape(name), c.httpOpts(o)...)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Name   string `json:"name"`
+		Commit commit `json:"commit"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Commit.ID == "" {
+		return nil, fmt.Errorf("%s: branch %q has no commit", c.Repo, name)
+	}
+	return &forge.Branch{
+		Name:   resp.Name,
+		Commit: forge.Commit{SHA: resp.Commit.ID, Time: resp.Commit.Timestamp},
+	}, nil
+}
+
+// Tree returns the entries of the tree of the commit, recursively.
+// The Gitea API paginates the listing; truncated is true when it has more
+// than 100 pages.
+func (c *Client) Tree(ctx context.Context, commit string, o ...netutil.HTTPOpt) (entries []forge.TreeEntry, truncated bool, err error) {
+	for page := 1; page <= maxTreePages; page++ {
+		urlStr := fmt.Sprintf("%s/git/trees/%s?recursive=true&page=%d", c.repoURL(), url.PathEscape(commit), page)
+		b, err := netutil.Get(ctx, urlStr, c.httpOpts(o)...)
+		if err != nil {
+			return nil, false, err
+		}
+		var resp struct {
+			Tree      []forge.TreeEntry `json:"tree"`
+			Truncated bool              `json:"truncated"`
+		}
+		if err = json.Unmarshal(b, &resp); err != nil {
+			return nil, false, err
+		}
+		entries = append(entries, resp.Tree...)
+		if !resp.Truncated || len(resp.Tree) == 0 {
+			return entries, false, nil
+		}
+	}
+	return entries, true, nil
+}
+
+func (c *Client) ContentURL(commit, p string) string {
+	return fmt.Sprintf("%s/%s/%s/raw/commit/%s/%s",
+		c.RawURL, c.Repo.Owner, c.Repo.Repo, path.Clean(commit), path.Clean(p))
+}
@@ -0,0 +1,61 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+)
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/example/foo":
+			fmt.Fprint(w, `{"full_name": "example/foo", "default_branch": "main"}`)
+		case "/api/v1/repos/example/foo/tags":
+			fmt.Fprint(w, `[{"name": "v1.0.0", "commit": {"sha": "aaa"}}]`)
+		case "/api/v1/repos/example/foo/branches":
+			fmt.Fprint(w, `[{"name": "main"}, {"name": "release-1.0"}]`)
+		case "/api/v1/repos/example/foo/branches/release-1.0":
+			fmt.Fprint(w, `{"name": "release-1.0", "commit": {"id": "bbb", "timestamp": "2026-10-16T01:02:03Z"}}`)
+		case "/api/v1/repos/example/foo/git/trees/bbb":
+			switch r.URL.Query().Get("page") {
+			case "1":
+				fmt.Fprint(w, `{"sha": "bbb", "tree": [{"path": "go.mod", "type": "blob"}], "truncated": true, "page": 1}`)
+			case "2":
+				fmt.Fprint(w, `{"sha": "bbb", "tree": [{"path": "api/go.mod", "type": "blob"}], "truncated": false, "page": 2}`)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.TODO() // t.Context is too new
+	c := &Client{Repo: forge.Repo{Forge: forge.Gitea, Host: "codeberg.org", Owner: "example", Repo: "foo"}, APIURL: srv.URL + "/api/v1"}
+	tags, err := c.Tags(ctx, 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []forge.Tag{{Name: "v1.0.0", Commit: forge.Commit{SHA: "aaa"}}}, tags)
+	def, err := c.DefaultBranch(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, "main", def)
+	branches, err := c.Branches(ctx, 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"main", "release-1.0"}, branches)
+	br, err := c.Branch(ctx, "release-1.0")
+	assert.NilError(t, err)
+	assert.Equal(t, "bbb", br.Commit.SHA)
+	assert.Equal(t, time.Date(2026, 10, 16, 1, 2, 3, 0, time.UTC), br.Commit.Time.UTC())
+	entries, truncated, err := c.Tree(ctx, br.Commit.SHA)
+	assert.NilError(t, err)
+	assert.Assert(t, !truncated)
+	assert.DeepEqual(t, []forge.TreeEntry{{Path: "go.mod", Type: "blob"}, {Path: "api/go.mod", Type: "blob"}}, entries)
+
+	assert.Equal(t, "https://codeberg.org/example/foo/raw/commit/aaa/go.mod", New(c.Repo).ContentURL("aaa", "go.mod"))
+}
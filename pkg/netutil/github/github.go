@@ -1,68 +1,235 @@
+// Package github implements the [forge.Client] of GitHub.
 package github
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"path"
-	"regexp"
+	"slices"
+	"time"
 
 	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
 )
 
-func NewRepo(urlStr string) (*Repo, error) {
-	pattern := regexp.MustCompile(`^https?://(?:www\.)?github\.com/([^/]+)/([^/]+?)(?:\.git)?(?:/.*)?$`)
-	matches := pattern.FindStringSubmatch(urlStr)
-	if len(matches) != 3 {
-		return nil, fmt.Errorf("invalid GitHub repo URL: %q", urlStr)
+func init() {
+	forge.Register(forge.GitHub, func(repo forge.Repo) (forge.Client, error) {
+		return New(repo), nil
+	})
+}
+
+const (
+	// APIURL is the base URL of the API of github.com.
+	APIURL = "https://api.github.com"
+	// RawURL is the base URL of the raw contents of github.com.
+	RawURL = "https://raw.githubusercontent.com"
+)
+
+// New instantiates the [Client] of repo.
+// The endpoints of GitHub Enterprise Server are assumed for hosts other than
+// github.com, unless configured with [forge.RegisterHost].
+func New(repo forge.Repo) *Client {
+	apiURL, rawURL := APIURL, RawURL
+	if host := repo.Hostname(); host != "github.com" {
+		apiURL = "https://" + host + "/api/v3"
+		rawURL = "https://" + host + "/raw"
 	}
-	repo := &Repo{
-		Owner: matches[1],
-		Repo:  matches[2],
+	c := &Client{Repo: repo}
+	c.APIURL, c.RawURL = forge.Endpoints(repo, apiURL, rawURL)
+	return c
+}
+
+// Client is the [forge.Client] of GitHub.
+type Client struct {
+	Repo forge.Repo
+	// APIURL is the base URL of the REST API, e.g. "https://api.github.com".
+	APIURL string
+	// RawURL is the base URL of the raw contents, e.g. "https://raw.githubusercontent.com".
+	RawURL string
+}
+
+var (
+	_ forge.Client      = (*Client)(nil)
+	_ forge.OwnerLister = (*Client)(nil)
+)
+
+// httpOpts appends the token of the forge host to o, for the API served on
+// another host (see [netutil.WithHostToken]).
+func (c *Client) httpOpts(o []netutil.HTTPOpt) []netutil.HTTPOpt {
+	return append(slices.Clip(o), netutil.WithHostToken(c.Repo.Hostname()))
+}
+
+// TagsPerPage is the number of the tags fetched per request by [Client.Tags]
+// (the maximum allowed by the GitHub API).
+const TagsPerPage = 100
+
+func (c *Client) Tags(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]forge.Tag, error) {
+	var res []forge.Tag
+	for page := 1; ; page++ {
+		if maxPages > 0 && page > maxPages {
+			forge.WarnTruncated(ctx, c.Repo, "tags", maxPages)
+			break
+		}
+		urlStr := fmt.Sprintf("%s/repos/%s/%s/tags?per_page=%d&page=%d", c.APIURL, c.Repo.Owner, c.Repo.Repo, TagsPerPage, page)
+		b, err := netutil.Get(ctx, urlStr, c.httpOpts(o)...)
+		if err != nil {
+			return res, err
+		}
+		var tags []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				SHA string `json:"sha"`
+			} `json:"commit"`
+		}
+		if err = json.Unmarshal(b, &tags); err != nil {
+			return res, err
+		}
+		for _, t := range tags {
+			res = append(res, forge.Tag{Name: t.Name, Commit: forge.Commit{SHA: t.Commit.SHA}})
+		}
+		if len(tags) < TagsPerPage {
+			break
+		}
 	}
-	return repo, nil
+	return res, nil
 }
 
-type Repo struct {
-	Owner string `json:"owner"`
-	Repo  string `json:"repo"`
+func (c *Client) DefaultBranch(ctx context.Context, o ...netutil.HTTPOpt) (string, error) {
+	urlStr := fmt.Sprintf("%s/repos/%s/%s", c.APIURL, c.Repo.Owner, c.Repo.Repo)
+	b, err := netutil.Get(ctx, urlStr, c.httpOpts(o)...)
+	if err != nil {
+		return "", err
+	}
+	var info struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err = json.Unmarshal(b, &info); err != nil {
+		return "", err
+	}
+	if info.DefaultBranch == "" {
+		return "", fmt.Errorf("%s: no default branch", c.Repo)
+	}
+	return info.DefaultBranch, nil
 }
 
-type Tag struct {
-	Name       string `json:"name"`
-	ZipballURL string `json:"zipball_url,omitempty"`
-	TarballURL string `json:"tarball_url,omitempty"`
-	Commit     struct {
-		SHA string `json:"sha"`
-		URL string `json:"url,omitempty"`
-	} `json:"commit"`
-	NodeID string `json:"node_id,omitempty"`
+func (c *Client) Branches(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]string, error) {
+	var res []string
+	for page := 1; ; page++ {
+		if maxPages > 0 && page > maxPages {
+			forge.WarnTruncated(ctx, c.Repo, "branches", maxPages)
+			break
+		}
+		urlStr := fmt.Sprintf("%s/repos/%s/%s/branches?per_page=%d&page=%d", c.APIURL, c.Repo.Owner, c.Repo.Repo, TagsPerPage, page)
+		b, err := netutil.Get(ctx, urlStr, c.httpOpts(o)...)
+		if err != nil {
+			return res, err
+		}
+		var branches []struct {
+			Name string `json:"name"`
+		}
+		if err = json.Unmarshal(b, &branches); err != nil {
+			return res, err
+		}
+		for _, br := range branches {
+			res = append(res, br.Name)
+		}
+		if len(branches) < TagsPerPage {
+			break
+		}
+	}
+	return res, nil
 }
 
-func (t *Tag) Compact() Tag {
-	n := *t
-	n.ZipballURL = ""
-	n.TarballURL = ""
-	n.Commit.URL = ""
-	n.NodeID = ""
-	return n
+// OwnerRepos returns the repositories of the owner whose primary language
+// (as detected by GitHub) is Go, skipping archived repositories and forks.
+func (c *Client) OwnerRepos(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]forge.Repo, error) {
+	var res []forge.Repo
+	for page := 1; ; page++ {
+		if maxPages > 0 && page > maxPages {
+			forge.WarnTruncated(ctx, c.Repo, "owner repositories", maxPages)
+			break
+		}
+		// "/users/{owner}/repos" also lists the public repositories of organizations.
+		urlStr := fmt.Sprintf("%s/users/%s/repos?type=owner&per_page=%d&page=%d", c.APIURL, url.PathEscape(c.Repo.Owner), TagsPerPage, page)
+		b, err := netutil.Get(ctx, urlStr, c.httpOpts(o)...)
+		if err != nil {
+			return res, err
+		}
+		var repos []struct {
+			Name     string `json:"name"`
+			Language string `json:"language"`
+			Archived bool   `json:"archived"`
+			Fork     bool   `json:"fork"`
+		}
+		if err = json.Unmarshal(b, &repos); err != nil {
+			return res, err
+		}
+		for _, r := range repos {
+			if r.Language != "Go" || r.Archived || r.Fork {
+				continue
+			}
+			repo := c.Repo
+			repo.Repo = r.Name
+			res = append(res, repo)
+		}
+		if len(repos) < TagsPerPage {
+			break
+		}
+	}
+	return res, nil
 }
 
-// Tags returns 30 tags at most.
-func (r *Repo) Tags(ctx context.Context, o ...netutil.HTTPOpt) ([]Tag, error) {
-	urlStr := fmt.Sprintf("https://api.github.com/repos/%s/%s/tags", r.Owner, r.Repo)
-	b, err := netutil.Get(ctx, urlStr, o...)
+func (c *Client) Branch(ctx context.Context, name string, o ...netutil.HTTPOpt) (*forge.Branch, error) {
+	urlStr := fmt.Sprintf("%s/repos/%s/%s/branches/%s", c.APIURL, c.Repo.Owner, c.Repo.Repo, url.PathEscape(name))
+	b, err := netutil.Get(ctx, urlStr, c.httpOpts(o)...)
 	if err != nil {
 		return nil, err
 	}
-	var tags []Tag
-	if err = json.Unmarshal(b, &tags); err != nil {
+	var resp struct {
+		Name   string `json:"name"`
+		Commit struct {
+			SHA    string `json:"sha"`
+			Commit struct {
+				Committer struct {
+					Date time.Time `json:"date"`
+				} `json:"committer"`
+			} `json:"commit"`
+		} `json:"commit"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
 		return nil, err
 	}
-	return tags, nil
+	if resp.Commit.SHA == "" {
+		return nil, fmt.Errorf("%s: branch %q has no commit", c.Repo, name)
+	}
+	return &forge.Branch{
+		Name:   resp.Name,
+		Commit: forge.Commit{SHA: resp.Commit.SHA, Time: resp.Commit.Commit.Committer.Date},
+	}, nil
+}
+
+// Tree returns the entries of the tree of the commit, recursively.
+// The GitHub API truncates the listing of very large trees; truncated reports
+// whether it did.
+func (c *Client) Tree(ctx context.Context, commit string, o ...netutil.HTTPOpt) (entries []forge.TreeEntry, truncated bool, err error) {
+	urlStr := fmt.Sprintf("%s/repos/%s/%s/git/trees/%s?recursive=1", c.APIURL, c.Repo.Owner, c.Repo.Repo, url.PathEscape(commit))
+	b, err := netutil.Get(ctx, urlStr, c.httpOpts(o)...)
+	if err != nil {
+		return nil, false, err
+	}
+	var resp struct {
+		Tree      []forge.TreeEntry `json:"tree"`
+		Truncated bool              `json:"truncated"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return nil, false, err
+	}
+	return resp.Tree, resp.Truncated, nil
 }
 
-func (r *Repo) ContentURL(commit, p string) string {
-	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s",
-		r.Owner, r.Repo, path.Clean(commit), path.Clean(p))
+func (c *Client) ContentURL(commit, p string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s",
+		c.RawURL, c.Repo.Owner, c.Repo.Repo, path.Clean(commit), path.Clean(p))
 }
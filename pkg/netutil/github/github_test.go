@@ -2,37 +2,127 @@ package github
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
-)
 
-func TestNewRepo(t *testing.T) {
-	cases := []struct {
-		url      string
-		expected *Repo
-	}{
-		{"https://github.com/containerd/nerdctl", &Repo{Owner: "containerd", Repo: "nerdctl"}},
-		{"http://www.github.com/containerd/nerdctl.git", &Repo{Owner: "containerd", Repo: "nerdctl"}},
-	}
-	for _, tc := range cases {
-		got, err := NewRepo(tc.url)
-		if tc.expected != nil {
-			assert.NilError(t, err)
-			assert.DeepEqual(t, tc.expected, got)
-		} else {
-			assert.ErrorContains(t, err, "invalid")
-		}
-	}
-}
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+)
 
 func TestTags(t *testing.T) {
 	ctx := context.TODO() // t.Context is too new
-	repo, err := NewRepo("https://github.com/containerd/containerd")
+	repo, err := forge.ParseRepoURL("https://github.com/containerd/containerd")
 	assert.NilError(t, err)
-	tags, err := repo.Tags(ctx)
+	tags, err := New(*repo).Tags(ctx, 1)
 	assert.NilError(t, err)
 	for _, tag := range tags {
 		t.Logf("%s\t%s", tag.Name, tag.Commit.SHA)
 	}
 }
+
+func TestTagsPagination(t *testing.T) {
+	const total = TagsPerPage*2 + 5
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/repos/example/foo/tags", r.URL.Path)
+		assert.Equal(t, strconv.Itoa(TagsPerPage), r.URL.Query().Get("per_page"))
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		assert.NilError(t, err)
+		var tags []forge.Tag
+		for i := (page - 1) * TagsPerPage; i < min(page*TagsPerPage, total); i++ {
+			tags = append(tags, forge.Tag{Name: fmt.Sprintf("v1.0.%d", i)})
+		}
+		assert.NilError(t, json.NewEncoder(w).Encode(tags))
+	}))
+	defer srv.Close()
+
+	ctx := context.TODO()
+	repo := &Client{Repo: forge.Repo{Owner: "example", Repo: "foo"}, APIURL: srv.URL}
+	tags, err := repo.Tags(ctx, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, total, len(tags))
+	assert.Equal(t, "v1.0.204", tags[total-1].Name)
+	assert.Equal(t, 3, requests)
+
+	requests = 0
+	tags, err = repo.Tags(ctx, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, TagsPerPage*2, len(tags))
+	assert.Equal(t, 2, requests)
+}
+
+func TestBranchesAndTree(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/example/foo":
+			fmt.Fprint(w, `{"name": "foo", "default_branch": "master"}`)
+		case "/repos/example/foo/branches":
+			fmt.Fprint(w, `[{"name": "master"}, {"name": "release-1.33"}]`)
+		case "/repos/example/foo/git/trees/abc":
+			assert.Equal(t, "1", r.URL.Query().Get("recursive"))
+			fmt.Fprint(w, `{"sha": "abc", "tree": [{"path": "go.mod", "type": "blob"}, {"path": "api", "type": "tree"}], "truncated": true}`)
+		case "/repos/example/foo/branches/release-1.33":
+			fmt.Fprint(w, `{"name": "release-1.33", "commit": {"sha": "abc", "commit": {"committer": {"date": "2026-10-16T01:02:03Z"}}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.TODO()
+	repo := &Client{Repo: forge.Repo{Owner: "example", Repo: "foo"}, APIURL: srv.URL}
+	def, err := repo.DefaultBranch(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, "master", def)
+	branches, err := repo.Branches(ctx, 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"master", "release-1.33"}, branches)
+	br, err := repo.Branch(ctx, "release-1.33")
+	assert.NilError(t, err)
+	assert.Equal(t, "release-1.33", br.Name)
+	assert.Equal(t, "abc", br.Commit.SHA)
+	assert.Equal(t, time.Date(2026, 10, 16, 1, 2, 3, 0, time.UTC), br.Commit.Time.UTC())
+	_, err = repo.Branch(ctx, "missing")
+	assert.ErrorContains(t, err, "404")
+
+	entries, truncated, err := repo.Tree(ctx, br.Commit.SHA)
+	assert.NilError(t, err)
+	assert.Assert(t, truncated)
+	assert.DeepEqual(t, []forge.TreeEntry{{Path: "go.mod", Type: "blob"}, {Path: "api", Type: "tree"}}, entries)
+}
+
+func TestOwnerRepos(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/users/example/repos", r.URL.Path)
+		assert.Equal(t, "owner", r.URL.Query().Get("type"))
+		fmt.Fprint(w, `[
+	{"name": "foo", "language": "Go"},
+	{"name": "docs", "language": "HTML"},
+	{"name": "old", "language": "Go", "archived": true},
+	{"name": "fork", "language": "Go", "fork": true},
+	{"name": "bar", "language": "Go"}
+]`)
+	}))
+	defer srv.Close()
+
+	repo := &Client{Repo: forge.Repo{Owner: "example", Repo: "foo"}, APIURL: srv.URL}
+	repos, err := repo.OwnerRepos(context.TODO(), 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []forge.Repo{{Owner: "example", Repo: "foo"}, {Owner: "example", Repo: "bar"}}, repos)
+}
+
+func TestContentURL(t *testing.T) {
+	repo := forge.Repo{Owner: "example", Repo: "foo"}
+	assert.Equal(t, "https://raw.githubusercontent.com/example/foo/abc/sub/go.mod", New(repo).ContentURL("abc", "sub/go.mod"))
+	repo.Forge, repo.Host = forge.GitHub, "github.example.com"
+	c := New(repo)
+	assert.Equal(t, "https://github.example.com/api/v3", c.APIURL)
+	assert.Equal(t, "https://github.example.com/raw/example/foo/abc/go.mod", c.ContentURL("abc", "go.mod"))
+}
@@ -0,0 +1,191 @@
+// Package gitlab implements the [forge.Client] of GitLab.
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+)
+
+func init() {
+	forge.Register(forge.GitLab, func(repo forge.Repo) (forge.Client, error) {
+		return New(repo), nil
+	})
+}
+
+// New instantiates the [Client] of repo.
+// The endpoints can be configured with [forge.RegisterHost].
+func New(repo forge.Repo) *Client {
+	base := "https://" + repo.Hostname()
+	c := &Client{Repo: repo}
+	c.APIURL, c.RawURL = forge.Endpoints(repo, base+"/api/v4", base)
+	return c
+}
+
+// Client is the [forge.Client] of GitLab (API v4).
+type Client struct {
+	Repo forge.Repo
+	// APIURL is the base URL of the API, e.g. "https://gitlab.com/api/v4".
+	APIURL string
+	// RawURL is the base URL of the raw contents, e.g. "https://gitlab.com".
+	RawURL string
+}
+
+var _ forge.Client = (*Client)(nil)
+
+// httpOpts appends the token of the forge host to o, for the API served on
+// another host (see [netutil.WithHostToken]).
+func (c *Client) httpOpts(o []netutil.HTTPOpt) []netutil.HTTPOpt {
+	return append(slices.Clip(o), netutil.WithHostToken(c.Repo.Hostname()))
+}
+
+// PerPage is the number of the items fetched per request
+// (the maximum allowed by the GitLab API).
+const PerPage = 100
+
+// maxTreePages is the maximum number of the pages of [Client.Tree].
+const maxTreePages = 100
+
+// projectURL returns the API URL of the project, with the URL-encoded path as the ID.
+func (c *Client) projectURL() string {
+	return c.APIURL + "/projects/" + url.PathEscape(path.Join(c.Repo.Owner, c.Repo.Repo))
+}
+
+type commit struct {
+	ID            string    `json:"id"`
+	CommittedDate time.Time `json:"committed_date"`
+}
+
+func (c *commit) toForge() forge.Commit {
+	return forge.Commit{SHA: c.ID, Time: c.CommittedDate}
+}
+
+// getPages fetches the pages of a list, and calls f with the body of each page.
+// f returns the number of the items in the page.
+func getPages(ctx context.Context, urlStr string, maxPages int, o []netutil.HTTPOpt, f func([]byte) (int, error)) (exhausted bool, err error) {
+	sep := "?"
+	if strings.Contains(urlStr, "?") {
+		sep = "&"
+	}
+	for page := 1; maxPages <= 0 || page <= maxPages; page++ {
+		b, err := netutil.Get(ctx, fmt.Sprintf("%s%sper_page=%d&page=%d", urlStr, sep, PerPage, page), o...)
+		if err != nil {
+			return false, err
+		}
+		n, err := f(b)
+		if err != nil {
+			return false, err
+		}
+		if n < PerPage {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *Client) Tags(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]forge.Tag, error) {
+	var res []forge.Tag
+	exhausted, err := getPages(ctx, c.projectURL()+"/repository/tags", maxPages, c.httpOpts(o), func(b []byte) (int, error) {
+		var tags []struct {
+			Name   string `json:"name"`
+			Commit commit `json:"commit"`
+		}
+		if err := json.Unmarshal(b, &tags); err != nil {
+			return 0, err
+		}
+		for _, t := range tags {
+			res = append(res, forge.Tag{Name: t.Name, Commit: forge.Commit{SHA: t.Commit.ID}})
+		}
+		return len(tags), nil
+	})
+	if err == nil && !exhausted {
+		forge.WarnTruncated(ctx, c.Repo, "tags", maxPages)
+	}
+	return res, err
+}
+
+func (c *Client) DefaultBranch(ctx context.Context, o ...netutil.HTTPOpt) (string, error) {
+	b, err := netutil.Get(ctx, c.projectURL(), c.httpOpts(o)...)
+	if err != nil {
+		return "", err
+	}
+	var info struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err = json.Unmarshal(b, &info); err != nil {
+		return "", err
+	}
+	if info.DefaultBranch == "" {
+		return "", fmt.Errorf("%s: no default branch", c.Repo)
+	}
+	return info.DefaultBranch, nil
+}
+
+func (c *Client) Branches(ctx context.Context, maxPages int, o ...netutil.HTTPOpt) ([]string, error) {
+	var res []string
+	exhausted, err := getPages(ctx, c.projectURL()+"/repository/branches", maxPages, c.httpOpts(o), func(b []byte) (int, error) {
+		var branches []struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(b, &branches); err != nil {
+			return 0, err
+		}
+		for _, br := range branches {
+			res = append(res, br.Name)
+		}
+		return len(branches), nil
+	})
+	if err == nil && !exhausted {
+		forge.WarnTruncated(ctx, c.Repo, "branches", maxPages)
+	}
+	return res, err
+}
+
+func (c *Client) Branch(ctx context.Context, name string, o ...netutil.HTTPOpt) (*forge.Branch, error) {
+	b, err := netutil.Get(ctx, c.projectURL()+"/repository/branches/"+url.PathEscape(name), c.httpOpts(o)...)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Name   string `json:"name"`
+		Commit commit `json:"commit"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Commit.ID == "" {
+		return nil, fmt.Errorf("%s: branch %q has no commit", c.Repo, name)
+	}
+	return &forge.Branch{Name: resp.Name, Commit: resp.Commit.toForge()}, nil
+}
+
+// Tree returns the entries of the tree of the commit, recursively.
+// truncated is true when the tree has more than 10,000 entries.
+func (c *Client) Tree(ctx context.Context, commit string, o ...netutil.HTTPOpt) (entries []forge.TreeEntry, truncated bool, err error) {
+	urlStr := c.projectURL() + "/repository/tree?recursive=true&ref=" + url.QueryEscape(commit)
+	exhausted, err := getPages(ctx, urlStr, maxTreePages, c.httpOpts(o), func(b []byte) (int, error) {
+		var page []forge.TreeEntry
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, err
+		}
+		entries = append(entries, page...)
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return entries, !exhausted, nil
+}
+
+func (c *Client) ContentURL(commit, p string) string {
+	return fmt.Sprintf("%s/%s/%s/-/raw/%s/%s",
+		c.RawURL, c.Repo.Owner, c.Repo.Repo, path.Clean(commit), path.Clean(p))
+}
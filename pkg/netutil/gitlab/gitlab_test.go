@@ -0,0 +1,59 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+)
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/example%2Fsub%2Ffoo":
+			fmt.Fprint(w, `{"path_with_namespace": "example/sub/foo", "default_branch": "main"}`)
+		case "/api/v4/projects/example%2Fsub%2Ffoo/repository/tags":
+			assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+			fmt.Fprint(w, `[{"name": "v1.0.0", "commit": {"id": "aaa", "committed_date": "2026-10-16T01:02:03.000+00:00"}}]`)
+		case "/api/v4/projects/example%2Fsub%2Ffoo/repository/branches":
+			fmt.Fprint(w, `[{"name": "main"}, {"name": "release-1.0"}]`)
+		case "/api/v4/projects/example%2Fsub%2Ffoo/repository/branches/release-1.0":
+			fmt.Fprint(w, `{"name": "release-1.0", "commit": {"id": "bbb", "committed_date": "2026-10-16T01:02:03.000+00:00"}}`)
+		case "/api/v4/projects/example%2Fsub%2Ffoo/repository/tree":
+			assert.Equal(t, "true", r.URL.Query().Get("recursive"))
+			assert.Equal(t, "bbb", r.URL.Query().Get("ref"))
+			fmt.Fprint(w, `[{"path": "go.mod", "type": "blob"}, {"path": "api", "type": "tree"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.TODO() // t.Context is too new
+	c := &Client{Repo: forge.Repo{Forge: forge.GitLab, Host: "gitlab.com", Owner: "example/sub", Repo: "foo"}, APIURL: srv.URL + "/api/v4"}
+	tags, err := c.Tags(ctx, 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []forge.Tag{{Name: "v1.0.0", Commit: forge.Commit{SHA: "aaa"}}}, tags)
+	def, err := c.DefaultBranch(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, "main", def)
+	branches, err := c.Branches(ctx, 0)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"main", "release-1.0"}, branches)
+	br, err := c.Branch(ctx, "release-1.0")
+	assert.NilError(t, err)
+	assert.Equal(t, "bbb", br.Commit.SHA)
+	assert.Equal(t, time.Date(2026, 10, 16, 1, 2, 3, 0, time.UTC), br.Commit.Time.UTC())
+	entries, truncated, err := c.Tree(ctx, br.Commit.SHA)
+	assert.NilError(t, err)
+	assert.Assert(t, !truncated)
+	assert.DeepEqual(t, []forge.TreeEntry{{Path: "go.mod", Type: "blob"}, {Path: "api", Type: "tree"}}, entries)
+
+	assert.Equal(t, "https://gitlab.com/example/sub/foo/-/raw/aaa/sub/go.mod", New(c.Repo).ContentURL("aaa", "sub/go.mod"))
+}
@@ -8,12 +8,21 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/progress"
 )
 
 type httpOpts struct {
-	client      *http.Client
-	maxBytes    int64
-	bearerToken string
+	client         *http.Client
+	maxBytes       int64
+	bearerToken    string
+	basicAuth      *basicAuth
+	validators     ValidatorStore
+	maxRetries     int
+	retryBaseDelay time.Duration
+	maxRetryWait   time.Duration
+	onProgress     progress.Handler
 }
 
 type HTTPOpt func(opts *httpOpts, urlStr string) error
@@ -41,22 +50,64 @@ func WithBearerToken(bearerToken string) HTTPOpt {
 	}
 }
 
+type basicAuth struct {
+	username string
+	password string
+}
+
+// WithBasicAuth sends the basic authentication, instead of the bearer token.
+func WithBasicAuth(username, password string) HTTPOpt {
+	return func(opts *httpOpts, _ string) error {
+		opts.basicAuth = &basicAuth{username: username, password: password}
+		return nil
+	}
+}
+
+// Validator is the validator of a response, for conditional requests.
+type Validator struct {
+	ETag string `json:"etag"`
+	// Body is returned on 304 Not Modified.
+	Body []byte `json:"body"`
+}
+
+// ValidatorStore stores the [Validator] of the response of each URL.
+// ValidatorStore has to be safe for concurrent use.
+type ValidatorStore interface {
+	LoadValidator(urlStr string) (*Validator, bool)
+	StoreValidator(urlStr string, v *Validator)
+}
+
+// WithValidatorStore sends If-None-Match with the ETag stored in store, and
+// returns the stored body on 304 Not Modified.
+// The ETag and the body of a 200 response are stored in store.
+func WithValidatorStore(store ValidatorStore) HTTPOpt {
+	return func(opts *httpOpts, _ string) error {
+		opts.validators = store
+		return nil
+	}
+}
+
 func isGitHubDomain(urlStr string) (bool, error) {
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		return false, err
 	}
-	hostname := u.Hostname()
+	return isGitHubHost(u.Hostname()), nil
+}
+
+func isGitHubHost(hostname string) bool {
 	hostname = strings.TrimSuffix(hostname, ".")
 	switch hostname {
 	case "github.com", "api.github.com", "raw.githubusercontent.com":
-		return true, nil
+		return true
 	}
-	return false, nil
+	return false
 }
 
 // WithAutoGitHubToken automatically sends $GITHUB_TOKEN so as to relax the API rate limit.
 // https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api
+//
+// Deprecated: Use [WithAutoToken], which also covers $GITHUB_TOKEN.
 func WithAutoGitHubToken() HTTPOpt {
 	return func(opts *httpOpts, urlStr string) error {
 		isGH, err := isGitHubDomain(urlStr)
@@ -64,12 +115,7 @@ func WithAutoGitHubToken() HTTPOpt {
 			return err
 		}
 		if isGH {
-			token := os.Getenv("GITHUB_TOKEN")
-			if token == "" {
-				// `gh` prioritizes $GH_TOKEN over $GITHUB_TOKEN
-				token = os.Getenv("GH_TOKEN")
-			}
-			if token != "" {
+			if token := gitHubTokenFromEnv(); token != "" {
 				opts.bearerToken = token
 			}
 		}
@@ -77,10 +123,20 @@ func WithAutoGitHubToken() HTTPOpt {
 	}
 }
 
+func gitHubTokenFromEnv() string {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		// `gh` prioritizes $GH_TOKEN over $GITHUB_TOKEN
+		token = os.Getenv("GH_TOKEN")
+	}
+	return token
+}
+
 type UnexpectedStatusCodeError struct {
 	URL        *url.URL
 	StatusCode int
 	Body       string
+	Header     http.Header
 }
 
 func (e *UnexpectedStatusCodeError) Error() string {
@@ -88,7 +144,11 @@ func (e *UnexpectedStatusCodeError) Error() string {
 }
 
 func Get(ctx context.Context, urlStr string, o ...HTTPOpt) ([]byte, error) {
-	var opts httpOpts
+	opts := httpOpts{
+		maxRetries:     DefaultMaxRetries,
+		retryBaseDelay: DefaultRetryBaseDelay,
+		maxRetryWait:   DefaultMaxRetryWait,
+	}
 	for _, f := range o {
 		if err := f(&opts, urlStr); err != nil {
 			return nil, err
@@ -104,12 +164,54 @@ func Get(ctx context.Context, urlStr string, o ...HTTPOpt) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if opts.bearerToken != "" {
+	switch {
+	case opts.basicAuth != nil:
+		req.SetBasicAuth(opts.basicAuth.username, opts.basicAuth.password)
+	case opts.bearerToken != "":
 		req.Header.Add("Authorization", "Bearer "+opts.bearerToken)
 	}
+	var validator *Validator
+	if opts.validators != nil {
+		if v, ok := opts.validators.LoadValidator(urlStr); ok && v.ETag != "" {
+			validator = v
+			req.Header.Add("If-None-Match", v.ETag)
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		body, header, err := do(req, &opts, validator)
+		if err == nil {
+			if etag := header.Get("ETag"); opts.validators != nil && etag != "" {
+				opts.validators.StoreValidator(urlStr, &Validator{ETag: etag, Body: body})
+			}
+			return body, nil
+		}
+		if ctx.Err() != nil || attempt > opts.maxRetries {
+			return nil, err
+		}
+		wait, reason, ok := retryDelay(err, attempt, &opts)
+		if !ok {
+			return nil, err
+		}
+		if wait > opts.maxRetryWait {
+			return nil, fmt.Errorf("%w (not retrying: %s, retry after %s exceeds %s)", err, reason, wait, opts.maxRetryWait)
+		}
+		if opts.onProgress != nil {
+			opts.onProgress(ctx, progress.Event{
+				Message: fmt.Sprintf("%s: %s; retrying in %s (%d/%d)",
+					req.URL.Redacted(), reason, wait.Round(time.Second), attempt, opts.maxRetries),
+			})
+		}
+		if err = sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// do sends req once. On 304 Not Modified, the body of validator is returned.
+func do(req *http.Request, opts *httpOpts, validator *Validator) ([]byte, http.Header, error) {
 	resp, err := opts.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 	lr := &io.LimitedReader{
@@ -118,14 +220,18 @@ func Get(ctx context.Context, urlStr string, o ...HTTPOpt) ([]byte, error) {
 	}
 	body, err := io.ReadAll(lr)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && validator != nil {
+		return validator.Body, nil, nil
 	}
 	if resp.StatusCode != 200 {
-		return nil, &UnexpectedStatusCodeError{
+		return nil, nil, &UnexpectedStatusCodeError{
 			URL:        req.URL,
 			StatusCode: resp.StatusCode,
 			Body:       string(body),
+			Header:     resp.Header,
 		}
 	}
-	return body, nil
+	return body, resp.Header, nil
 }
@@ -0,0 +1,246 @@
+package netutil
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/progress"
+)
+
+type mapValidatorStore struct {
+	sync.Mutex
+	m map[string]*Validator
+}
+
+func (s *mapValidatorStore) LoadValidator(urlStr string) (*Validator, bool) {
+	s.Lock()
+	defer s.Unlock()
+	v, ok := s.m[urlStr]
+	return v, ok
+}
+
+func (s *mapValidatorStore) StoreValidator(urlStr string, v *Validator) {
+	s.Lock()
+	defer s.Unlock()
+	s.m[urlStr] = v
+}
+
+func TestGetValidatorStore(t *testing.T) {
+	ctx := context.TODO() // t.Context is too new
+	body := "v1"
+	var notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	store := &mapValidatorStore{m: make(map[string]*Validator)}
+	for range 2 {
+		b, err := Get(ctx, srv.URL, WithValidatorStore(store))
+		assert.NilError(t, err)
+		assert.Equal(t, "v1", string(b))
+	}
+	assert.Equal(t, 1, notModified)
+
+	body = "v2"
+	b, err := Get(ctx, srv.URL, WithValidatorStore(store))
+	assert.NilError(t, err)
+	assert.Equal(t, "v2", string(b))
+	assert.Equal(t, 1, notModified)
+	assert.DeepEqual(t, &Validator{ETag: `"v2"`, Body: []byte("v2")}, store.m[srv.URL])
+
+	// Without the store, a 304 is never requested.
+	b, err = Get(ctx, srv.URL)
+	assert.NilError(t, err)
+	assert.Equal(t, "v2", string(b))
+	assert.Equal(t, 1, notModified)
+}
+
+func TestGetRetry(t *testing.T) {
+	ctx := context.TODO() // t.Context is too new
+	reset := strconv.FormatInt(time.Now().Unix(), 10)
+	cases := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		requests  int
+		events    int
+		status    int // expected status code of [UnexpectedStatusCodeError], or 0 for success
+	}{
+		{
+			name: "5xx",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			},
+			requests: 3,
+			events:   2,
+		},
+		{
+			name: "primary rate limit",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", reset)
+					w.WriteHeader(http.StatusForbidden)
+				},
+			},
+			requests: 2,
+			events:   1,
+		},
+		{
+			name: "secondary rate limit",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+			},
+			requests: 2,
+			events:   1,
+		},
+		{
+			name: "retry after exceeding max wait",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "3600")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+			},
+			requests: 1,
+			status:   http.StatusTooManyRequests,
+		},
+		{
+			name: "forbidden",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) },
+			},
+			requests: 1,
+			status:   http.StatusForbidden,
+		},
+		{
+			name: "not found",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			},
+			requests: 1,
+			status:   http.StatusNotFound,
+		},
+		{
+			name: "max retries",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+			},
+			requests: 4,
+			events:   3,
+			status:   http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= len(tc.responses) {
+					tc.responses[requests-1](w)
+					return
+				}
+				_, _ = w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+
+			var events []progress.Event
+			b, err := Get(ctx, srv.URL,
+				WithMaxRetries(3),
+				WithRetryBaseDelay(time.Millisecond),
+				WithMaxRetryWait(time.Minute),
+				WithProgressEventHandler(func(_ context.Context, ev progress.Event) {
+					events = append(events, ev)
+				}))
+			if tc.status == 0 {
+				assert.NilError(t, err)
+				assert.Equal(t, "ok", string(b))
+			} else {
+				var se *UnexpectedStatusCodeError
+				assert.Assert(t, errors.As(err, &se), "%v", err)
+				assert.Equal(t, tc.status, se.StatusCode)
+			}
+			assert.Equal(t, tc.requests, requests)
+			assert.Equal(t, tc.events, len(events))
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	opts := &httpOpts{retryBaseDelay: time.Second, maxRetryWait: DefaultMaxRetryWait}
+	cases := []struct {
+		name  string
+		err   error
+		delay time.Duration
+		ok    bool
+	}{
+		{"connection refused", fmt.Errorf("dial tcp: %w", syscall.ECONNREFUSED), time.Second, true},
+		{"connection reset", fmt.Errorf("read tcp: %w", syscall.ECONNRESET), time.Second, true},
+		{"unexpected EOF", io.ErrUnexpectedEOF, time.Second, true},
+		{"x509", x509.UnknownAuthorityError{}, 0, false},
+		{"other", errors.New(`unsupported protocol scheme "ftp"`), 0, false},
+		{"secondary rate limit", &UnexpectedStatusCodeError{
+			StatusCode: http.StatusForbidden,
+			Body:       `{"message":"You have exceeded a secondary rate limit."}`,
+		}, SecondaryRateLimitWait, true},
+		{"secondary rate limit with Retry-After", &UnexpectedStatusCodeError{
+			StatusCode: http.StatusForbidden,
+			Body:       `{"message":"You have exceeded a secondary rate limit."}`,
+			Header:     http.Header{"Retry-After": []string{"3"}},
+		}, 3 * time.Second, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, _, ok := retryDelay(tc.err, 1, opts)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.delay, delay)
+		})
+	}
+}
+
+func TestGetRetryPermanentError(t *testing.T) {
+	var events int
+	_, err := Get(context.TODO(), "ftp://example.com/",
+		WithProgressEventHandler(func(context.Context, progress.Event) { events++ }))
+	assert.ErrorContains(t, err, "unsupported protocol scheme")
+	assert.Equal(t, 0, events)
+}
+
+func TestGetRetryContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := Get(ctx, srv.URL,
+		WithRetryBaseDelay(time.Hour),
+		WithProgressEventHandler(func(context.Context, progress.Event) { cancel() }))
+	assert.ErrorIs(t, err, context.Canceled)
+}
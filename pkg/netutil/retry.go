@@ -0,0 +1,139 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/progress"
+)
+
+const (
+	// DefaultMaxRetries is the default number of retries of [Get].
+	DefaultMaxRetries = 5
+	// DefaultRetryBaseDelay is the default delay before the first retry.
+	// The delay is doubled on each retry.
+	DefaultRetryBaseDelay = time.Second
+	// DefaultMaxRetryWait is the default maximum wait before a retry.
+	// The primary rate limit of the GitHub API is reset every hour, so waiting
+	// for the reset has to be opted in with [WithMaxRetryWait].
+	DefaultMaxRetryWait = 5 * time.Minute
+	// SecondaryRateLimitWait is the minimum wait after hitting the secondary
+	// rate limit of the GitHub API without Retry-After.
+	// https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api#about-secondary-rate-limits
+	SecondaryRateLimitWait = time.Minute
+)
+
+// WithMaxRetries sets the number of retries on transient network errors,
+// 5xx responses, and rate limiting. Zero disables retries.
+func WithMaxRetries(maxRetries int) HTTPOpt {
+	return func(opts *httpOpts, _ string) error {
+		if maxRetries < 0 {
+			return fmt.Errorf("invalid max retries %d", maxRetries)
+		}
+		opts.maxRetries = maxRetries
+		return nil
+	}
+}
+
+// WithRetryBaseDelay sets the delay before the first retry, when the server
+// does not specify it.
+func WithRetryBaseDelay(d time.Duration) HTTPOpt {
+	return func(opts *httpOpts, _ string) error {
+		opts.retryBaseDelay = d
+		return nil
+	}
+}
+
+// WithMaxRetryWait sets the maximum wait before a retry.
+// When the server requests a longer wait (e.g., until the reset of the rate
+// limit), [Get] fails without waiting.
+func WithMaxRetryWait(d time.Duration) HTTPOpt {
+	return func(opts *httpOpts, _ string) error {
+		opts.maxRetryWait = d
+		return nil
+	}
+}
+
+// WithProgressEventHandler reports the waits before retries.
+func WithProgressEventHandler(onProgress progress.Handler) HTTPOpt {
+	return func(opts *httpOpts, _ string) error {
+		opts.onProgress = onProgress
+		return nil
+	}
+}
+
+// retryDelay returns the delay before retrying the attempt (1-origin) that
+// failed with err, and the reason of the retry.
+// ok is false when err is not retryable.
+func retryDelay(err error, attempt int, opts *httpOpts) (delay time.Duration, reason string, ok bool) {
+	backoff := opts.retryBaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > opts.maxRetryWait {
+		// overflow, or too long
+		backoff = opts.maxRetryWait
+	}
+	var se *UnexpectedStatusCodeError
+	if !errors.As(err, &se) {
+		if !isTransient(err) {
+			return 0, "", false
+		}
+		return backoff, err.Error(), true
+	}
+	reason = fmt.Sprintf("status code %d", se.StatusCode)
+	h := se.Header
+	secondaryRateLimited := (se.StatusCode == http.StatusForbidden || se.StatusCode == http.StatusTooManyRequests) &&
+		strings.Contains(strings.ToLower(se.Body), "secondary rate limit")
+	rateLimited := se.StatusCode == http.StatusTooManyRequests || secondaryRateLimited ||
+		(se.StatusCode == http.StatusForbidden && (h.Get("Retry-After") != "" || h.Get("X-RateLimit-Remaining") == "0"))
+	switch {
+	case rateLimited:
+		reason = "rate limited"
+	case se.StatusCode >= 500:
+	default:
+		return 0, "", false
+	}
+	// https://docs.github.com/en/rest/using-the-rest-api/best-practices-for-using-the-rest-api#handle-rate-limit-errors-appropriately
+	if sec, err := strconv.Atoi(h.Get("Retry-After")); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second, reason, true
+	}
+	if h.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Until(time.Unix(reset, 0)), opts.retryBaseDelay), reason, true
+		}
+	}
+	if secondaryRateLimited {
+		return max(backoff, SecondaryRateLimitWait), "secondary rate limited", true
+	}
+	return backoff, reason, true
+}
+
+// isTransient reports whether err is a network error that may succeed on
+// retry. Permanent errors, such as the certificate verification failures,
+// the unsupported schemes, and the unknown hosts, are not transient.
+func isTransient(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
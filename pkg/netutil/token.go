@@ -0,0 +1,226 @@
+package netutil
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// TokenEnvPrefix is the prefix of the environment variables of the tokens
+// of the hosts. See [TokenEnv].
+const TokenEnvPrefix = "GOSOCIALCHECK_TOKEN_"
+
+// TokenEnv returns the name of the environment variable of the token of the
+// host, e.g. "GOSOCIALCHECK_TOKEN_GITHUB_EXAMPLE_COM" for "github.example.com".
+func TokenEnv(host string) string {
+	var sb strings.Builder
+	sb.WriteString(TokenEnvPrefix)
+	for _, r := range strings.ToUpper(host) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+// WithAutoToken automatically sends the token of the host of the URL, so as
+// to access private repositories and to relax the API rate limit.
+// The token is looked up in the following order:
+//
+//  1. $GOSOCIALCHECK_TOKEN_<HOST> (see [TokenEnv])
+//  2. The password of the host in the netrc file ($NETRC, or ~/.netrc)
+//  3. $GITHUB_TOKEN or $GH_TOKEN, for GitHub
+//
+// The token of "github.com" is also used for "api.github.com" and
+// "raw.githubusercontent.com".
+func WithAutoToken() HTTPOpt {
+	return func(opts *httpOpts, urlStr string) error {
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return err
+		}
+		token, err := lookupToken(u.Hostname())
+		if err != nil || token == "" {
+			return err
+		}
+		opts.bearerToken = token
+		return nil
+	}
+}
+
+// WithHostToken sends the token of the forge host, looked up as in
+// [WithAutoToken], when no token has been set for the URL itself.
+// This covers the API and the raw contents of a forge served on other hosts
+// than the forge host, e.g. "api.github.example.com" for "github.example.com".
+func WithHostToken(host string) HTTPOpt {
+	return func(opts *httpOpts, _ string) error {
+		if opts.bearerToken != "" {
+			return nil
+		}
+		token, err := lookupToken(host)
+		if err != nil {
+			return err
+		}
+		opts.bearerToken = token
+		return nil
+	}
+}
+
+// lookupToken returns the token of the host, or an empty string.
+func lookupToken(host string) (string, error) {
+	hostname := strings.TrimSuffix(strings.ToLower(host), ".")
+	if hostname == "" {
+		return "", nil
+	}
+	hostnames := []string{hostname}
+	if isGitHubHost(hostname) && hostname != "github.com" {
+		hostnames = append(hostnames, "github.com")
+	}
+	for _, h := range hostnames {
+		if token := os.Getenv(TokenEnv(h)); token != "" {
+			return token, nil
+		}
+	}
+	machines, err := netrcMachines()
+	if err != nil {
+		return "", err
+	}
+	for _, h := range hostnames {
+		if token := machines[h].password; token != "" {
+			return token, nil
+		}
+	}
+	if isGitHubHost(hostname) {
+		return gitHubTokenFromEnv(), nil
+	}
+	return "", nil
+}
+
+// netrcMachines is [readNetrc], read once per process.
+var netrcMachines = sync.OnceValues(readNetrc)
+
+// WithNetrcAuth sends the login and the password of the host of the URL in
+// the netrc file as the basic authentication, as the go command does for
+// the module proxies. Any bearer token is dropped, so that the tokens of the
+// forges are not sent to the proxies.
+func WithNetrcAuth() HTTPOpt {
+	return func(opts *httpOpts, urlStr string) error {
+		opts.bearerToken = ""
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return err
+		}
+		machines, err := netrcMachines()
+		if err != nil {
+			return err
+		}
+		if m, ok := machines[strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")]; ok && m.password != "" {
+			opts.basicAuth = &basicAuth{username: m.login, password: m.password}
+		}
+		return nil
+	}
+}
+
+// netrcPath returns $NETRC, or ~/.netrc (~/_netrc on Windows).
+func netrcPath() (string, error) {
+	if v := os.Getenv("NETRC"); v != "" {
+		return v, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	base := ".netrc"
+	if runtime.GOOS == "windows" {
+		base = "_netrc"
+	}
+	return filepath.Join(home, base), nil
+}
+
+// netrcMachine is a machine entry of the netrc file.
+type netrcMachine struct {
+	login    string
+	password string
+}
+
+// readNetrc returns the entries of the netrc file, keyed by the lowercase
+// machine names. The "default" entry is ignored so as not to leak the password
+// to unrelated hosts.
+func readNetrc() (map[string]netrcMachine, error) {
+	p, err := netrcPath()
+	if err != nil {
+		// No home directory
+		return nil, nil
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseNetrc(string(b)), nil
+}
+
+// parseNetrc parses the netrc format, as in the go command.
+// https://www.gnu.org/software/inetutils/manual/html_node/The-_002enetrc-file.html
+func parseNetrc(data string) map[string]netrcMachine {
+	res := make(map[string]netrcMachine)
+	var (
+		machine string
+		entry   netrcMachine
+	)
+	flush := func() {
+		if _, ok := res[machine]; !ok && machine != "" && entry.password != "" {
+			res[machine] = entry
+		}
+		machine, entry = "", netrcMachine{}
+	}
+	inMacro := false
+	for line := range strings.Lines(data) {
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+		f := strings.Fields(line)
+		for i := 0; i < len(f); i++ {
+			switch f[i] {
+			case "machine":
+				flush()
+				if i+1 < len(f) {
+					machine = strings.ToLower(f[i+1])
+					i++
+				}
+			case "default":
+				flush()
+			case "login":
+				if i+1 < len(f) {
+					entry.login = f[i+1]
+				}
+				i++
+			case "account":
+				i++
+			case "password":
+				if i+1 < len(f) {
+					entry.password = f[i+1]
+				}
+				i++
+			case "macdef":
+				// The macro definition continues until a blank line.
+				inMacro = true
+				i = len(f)
+			}
+		}
+	}
+	flush()
+	return res
+}
@@ -0,0 +1,98 @@
+package netutil
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+)
+
+func TestTokenEnv(t *testing.T) {
+	assert.Equal(t, "GOSOCIALCHECK_TOKEN_GITHUB_EXAMPLE_COM", TokenEnv("github.example.com"))
+	assert.Equal(t, "GOSOCIALCHECK_TOKEN_GIT_EXAMPLE_COM_8443", TokenEnv("git-example.com:8443"))
+}
+
+func TestParseNetrc(t *testing.T) {
+	const netrc = `machine github.example.com login foo password token-ghe
+machine GitLab.example.com
+  login bar
+  password token-gl
+macdef init
+machine evil.example.com password token-macro
+
+default login anonymous password token-default
+`
+	assert.DeepEqual(t, map[string]netrcMachine{
+		"github.example.com": {login: "foo", password: "token-ghe"},
+		"gitlab.example.com": {login: "bar", password: "token-gl"},
+	}, parseNetrc(netrc), cmp.AllowUnexported(netrcMachine{}))
+}
+
+func TestWithAutoToken(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	assert.NilError(t, os.WriteFile(netrc, []byte("machine gitlab.example.com password token-netrc\n"), 0o600))
+	t.Setenv("NETRC", netrc)
+	netrcMachines = sync.OnceValues(readNetrc)
+	t.Cleanup(func() { netrcMachines = sync.OnceValues(readNetrc) })
+	t.Setenv("GITHUB_TOKEN", "token-github")
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv(TokenEnv("github.example.com"), "token-ghe")
+	t.Setenv(TokenEnv("gitlab.example.com"), "")
+
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://github.example.com/api/v3/repos/foo/bar/tags", "token-ghe"},
+		{"https://gitlab.example.com/api/v4/projects", "token-netrc"},
+		{"https://api.github.com/repos/foo/bar/tags", "token-github"},
+		{"https://raw.githubusercontent.com/foo/bar/HEAD/go.mod", "token-github"},
+		// The tokens must not be sent to the other hosts
+		{"https://example.com/github.example.com", ""},
+		{"https://github.example.com.evil.example.com/", ""},
+	}
+	for _, tc := range testCases {
+		var opts httpOpts
+		assert.NilError(t, WithAutoToken()(&opts, tc.url))
+		assert.Equal(t, tc.expected, opts.bearerToken, tc.url)
+	}
+
+	// The token of github.com is also used for api.github.com
+	t.Setenv(TokenEnv("github.com"), "token-github-com")
+	var opts httpOpts
+	assert.NilError(t, WithAutoToken()(&opts, "https://api.github.com/repos/foo/bar/tags"))
+	assert.Equal(t, "token-github-com", opts.bearerToken)
+
+	// The token of the forge host is used for the API on another host,
+	// unless the URL has its own token.
+	opts = httpOpts{}
+	assert.NilError(t, WithAutoToken()(&opts, "https://api.gitlab.example.com/api/v4/projects"))
+	assert.NilError(t, WithHostToken("gitlab.example.com")(&opts, "https://api.gitlab.example.com/api/v4/projects"))
+	assert.Equal(t, "token-netrc", opts.bearerToken)
+	t.Setenv(TokenEnv("api.gitlab.example.com"), "token-api")
+	opts = httpOpts{}
+	assert.NilError(t, WithAutoToken()(&opts, "https://api.gitlab.example.com/api/v4/projects"))
+	assert.NilError(t, WithHostToken("gitlab.example.com")(&opts, "https://api.gitlab.example.com/api/v4/projects"))
+	assert.Equal(t, "token-api", opts.bearerToken)
+}
+
+func TestWithNetrcAuth(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	assert.NilError(t, os.WriteFile(netrc, []byte("machine proxy.example.com login foo password bar\n"), 0o600))
+	t.Setenv("NETRC", netrc)
+	netrcMachines = sync.OnceValues(readNetrc)
+	t.Cleanup(func() { netrcMachines = sync.OnceValues(readNetrc) })
+
+	opts := httpOpts{bearerToken: "token"}
+	assert.NilError(t, WithNetrcAuth()(&opts, "https://proxy.example.com/example.com/foo/@v/list"))
+	assert.Equal(t, "", opts.bearerToken)
+	assert.DeepEqual(t, &basicAuth{username: "foo", password: "bar"}, opts.basicAuth, cmp.AllowUnexported(basicAuth{}))
+
+	opts = httpOpts{bearerToken: "token"}
+	assert.NilError(t, WithNetrcAuth()(&opts, "https://proxy.golang.org/example.com/foo/@v/list"))
+	assert.Equal(t, "", opts.bearerToken)
+	assert.Assert(t, opts.basicAuth == nil)
+}
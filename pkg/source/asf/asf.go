@@ -0,0 +1,131 @@
+// Package asf implements the trust source of the Apache Software Foundation
+// top-level projects written in Go.
+package asf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
+// ProjectsURL is the URL of the ASF project listing.
+const ProjectsURL = "https://projects.apache.org/json/foundation/projects.json"
+
+// ProjectsEnv is the environment variable that overrides [ProjectsURL] with
+// another URL or a local file (e.g., a downloaded dump of the listing).
+const ProjectsEnv = "GOSOCIALCHECK_ASF_PROJECTS"
+
+// Projects maps project IDs to the projects, as in the DOAP-derived JSON of
+// projects.apache.org.
+type Projects map[string]Project
+
+type Project struct {
+	Name     string `json:"name,omitempty"`
+	PMC      string `json:"pmc,omitempty"`
+	Category string `json:"category,omitempty"`
+	// ProgrammingLanguage is a comma-separated list, e.g. "Go, Java".
+	ProgrammingLanguage string `json:"programming-language,omitempty"`
+	// Repository lists the repository URLs, e.g. "https://github.com/apache/foo"
+	// or "https://gitbox.apache.org/repos/asf/foo.git".
+	Repository []string `json:"repository,omitempty"`
+}
+
+func init() {
+	source.Register(&Source{})
+}
+
+// Source is the [source.Source] of the ASF top-level projects.
+type Source struct{}
+
+func (*Source) Name() string {
+	return "asf"
+}
+
+func (*Source) Repositories(ctx context.Context, opts source.Opts) ([]source.Repository, error) {
+	if !slices.Contains(opts.Categories, categories.ASFTLP) {
+		return nil, nil
+	}
+	loc := ProjectsURL
+	if v := os.Getenv(ProjectsEnv); v != "" {
+		loc = v
+	}
+	b, err := read(ctx, loc, opts.HTTPOpts)
+	if err != nil {
+		return nil, err
+	}
+	var projects Projects
+	if err = json.Unmarshal(b, &projects); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", loc, err)
+	}
+	return projects.repositories(), nil
+}
+
+func read(ctx context.Context, loc string, httpOpts []netutil.HTTPOpt) ([]byte, error) {
+	if strings.HasPrefix(loc, "https://") || strings.HasPrefix(loc, "http://") {
+		return netutil.Get(ctx, loc, httpOpts...)
+	}
+	return os.ReadFile(loc)
+}
+
+// repositories returns the GitHub repositories of the top-level projects
+// written in Go, sorted by URL.
+func (projects Projects) repositories() []source.Repository {
+	var urls []string
+	for _, p := range projects {
+		if !p.isTLP() || !p.isGo() {
+			continue
+		}
+		for _, r := range p.Repository {
+			if u, ok := gitHubURL(r); ok && !slices.Contains(urls, u) {
+				urls = append(urls, u)
+			}
+		}
+	}
+	sort.Strings(urls)
+	res := make([]source.Repository, len(urls))
+	for i, u := range urls {
+		res[i] = source.Repository{URL: u, Category: categories.ASFTLP}
+	}
+	return res
+}
+
+// isTLP reports whether p belongs to a top-level project, not to the Incubator.
+func (p Project) isTLP() bool {
+	return p.PMC != "" && p.PMC != "incubator"
+}
+
+func (p Project) isGo() bool {
+	for _, l := range strings.Split(p.ProgrammingLanguage, ",") {
+		if strings.EqualFold(strings.TrimSpace(l), "Go") {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	gitHubRepoRE = regexp.MustCompile(`^https?://(?:www\.)?github\.com/(apache/[^/]+?)(?:\.git)?/?$`)
+	// ASF repositories are mirrored to github.com/apache.
+	asfGitRE = regexp.MustCompile(`^https?://(?:gitbox|git-wip-us)\.apache\.org/repos/asf/([^/?]+?)(?:\.git)?/?$`)
+)
+
+// gitHubURL returns "https://github.com/apache/<REPO>" for the GitHub or
+// the ASF git URL of a repository.
+func gitHubURL(r string) (string, bool) {
+	if m := gitHubRepoRE.FindStringSubmatch(r); m != nil {
+		return "https://github.com/" + m[1], true
+	}
+	if m := asfGitRE.FindStringSubmatch(r); m != nil {
+		return "https://github.com/apache/" + m[1], true
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+package asf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
+const projectsJSON = `{
+  "devlake": {
+    "name": "Apache DevLake",
+    "pmc": "devlake",
+    "programming-language": "Go, TypeScript",
+    "repository": ["https://github.com/apache/incubator-devlake", "https://gitbox.apache.org/repos/asf/devlake-website.git"]
+  },
+  "pulsar-client-go": {
+    "name": "Apache Pulsar Go Client",
+    "pmc": "pulsar",
+    "programming-language": "Go",
+    "repository": ["https://github.com/apache/pulsar-client-go.git", "https://github.com/apache/pulsar-client-go"]
+  },
+  "kafka": {
+    "name": "Apache Kafka",
+    "pmc": "kafka",
+    "programming-language": "Java, Scala",
+    "repository": ["https://github.com/apache/kafka"]
+  },
+  "podling": {
+    "name": "Apache Podling",
+    "pmc": "incubator",
+    "programming-language": "Go",
+    "repository": ["https://github.com/apache/incubator-podling"]
+  },
+  "elsewhere": {
+    "name": "Apache Elsewhere",
+    "pmc": "elsewhere",
+    "programming-language": "Golang, Go",
+    "repository": ["https://svn.apache.org/repos/asf/elsewhere"]
+  }
+}`
+
+func TestRepositories(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "projects.json")
+	assert.NilError(t, os.WriteFile(f, []byte(projectsJSON), 0o644))
+	t.Setenv(ProjectsEnv, f)
+
+	s := &Source{}
+	got, err := s.Repositories(context.TODO(), source.Opts{Categories: []string{categories.ASFTLP}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []source.Repository{
+		{URL: "https://github.com/apache/devlake-website", Category: categories.ASFTLP},
+		{URL: "https://github.com/apache/incubator-devlake", Category: categories.ASFTLP},
+		{URL: "https://github.com/apache/pulsar-client-go", Category: categories.ASFTLP},
+	}, got)
+
+	// The listing is not even read unless the category is trusted.
+	t.Setenv(ProjectsEnv, filepath.Join(t.TempDir(), "missing.json"))
+	got, err = s.Repositories(context.TODO(), source.Opts{Categories: categories.Default})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(got))
+}
@@ -0,0 +1,9 @@
+// Package builtin links in the built-in trust sources.
+package builtin
+
+import (
+	// Each package registers its source with source.Register.
+	_ "github.com/AkihiroSuda/gosocialcheck/pkg/source/asf"
+	_ "github.com/AkihiroSuda/gosocialcheck/pkg/source/cncf"
+	_ "github.com/AkihiroSuda/gosocialcheck/pkg/source/gotoolchain"
+)
@@ -1,5 +1,23 @@
+// Package cncf implements the trust source of the CNCF projects, listed in the
+// CLOMonitor data.
 package cncf
 
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
 const ProjectsURL = "https://raw.githubusercontent.com/cncf/clomonitor/refs/heads/main/data/cncf.yaml"
 
 type Projects []Project
@@ -21,3 +39,166 @@ type Repository struct {
 	URL       string   `yaml:"url,omitempty"`
 	CheckSets []string `yaml:"check_sets,omitempty"`
 }
+
+func init() {
+	source.Register(&Source{})
+}
+
+// Source is the [source.Source] of the CNCF projects.
+type Source struct {
+	// newClient is [forge.NewClient] unless replaced in tests.
+	newClient forge.NewClientFunc
+}
+
+func (*Source) Name() string {
+	return "cncf"
+}
+
+func (s *Source) Repositories(ctx context.Context, opts source.Opts) ([]source.Repository, error) {
+	b, err := netutil.Get(ctx, ProjectsURL, opts.HTTPOpts...)
+	if err != nil {
+		return nil, err
+	}
+	var projects Projects
+	if err = yaml.Unmarshal(b, &projects); err != nil {
+		return nil, err
+	}
+	var res []source.Repository
+	for _, p := range projects {
+		res = append(res, p.repositories(opts.Categories)...)
+	}
+	if slices.Contains(opts.Categories, categories.CNCFGraduatedSub) {
+		res = append(res, s.orgRepositories(ctx, projects, opts)...)
+	}
+	return res, nil
+}
+
+// orgRepositories lists the Go repositories that are not in projects, in the
+// orgs of the "code" and "code-lite" repositories of the graduated projects
+// (e.g., "kubernetes" and "kubernetes-sigs" for Kubernetes), as
+// [categories.CNCFGraduatedSub]. Orgs that cannot be listed are skipped with
+// a warning, as they only supplement the listed repositories.
+func (s *Source) orgRepositories(ctx context.Context, projects Projects, opts source.Opts) []source.Repository {
+	newClient := s.newClient
+	if newClient == nil {
+		newClient = forge.NewClient
+	}
+	listed := make(map[string]bool)
+	orgs := make(map[string]forge.Repo)
+	for _, p := range projects {
+		for _, r := range p.Repositories {
+			repo, err := forge.ParseRepoURL(r.URL)
+			if err != nil {
+				continue
+			}
+			listed[repoKey(*repo)] = true
+			if p.Maturity != "graduated" || !(slices.Contains(r.CheckSets, "code") || slices.Contains(r.CheckSets, "code-lite")) {
+				continue
+			}
+			if _, ok := orgs[orgKey(*repo)]; !ok {
+				orgs[orgKey(*repo)] = *repo
+			}
+		}
+	}
+	var res []source.Repository
+	for _, k := range slices.Sorted(maps.Keys(orgs)) {
+		repo := orgs[k]
+		client, err := newClient(repo)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to list the repositories of the org", "org", k, "error", err)
+			continue
+		}
+		lister, ok := client.(forge.OwnerLister)
+		if !ok {
+			slog.DebugContext(ctx, "the forge does not support listing the repositories of the org", "org", k)
+			continue
+		}
+		repos, err := lister.OwnerRepos(ctx, forge.DefaultMaxTagPages, opts.HTTPOpts...)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to list the repositories of the org", "org", k, "error", err)
+			continue
+		}
+		for _, r := range repos {
+			if listed[repoKey(r)] {
+				continue
+			}
+			listed[repoKey(r)] = true
+			res = append(res, source.Repository{
+				URL:      "https://" + path.Join(r.Hostname(), r.Owner, r.Repo),
+				Category: categories.CNCFGraduatedSub,
+			})
+		}
+	}
+	return res
+}
+
+// maturityCategories maps the maturity levels of the projects to categories.
+var maturityCategories = map[string]string{
+	"graduated":  categories.CNCFGraduated,
+	"incubating": categories.CNCFIncubating,
+	"sandbox":    categories.CNCFSandbox,
+}
+
+// repositories returns the repositories of p in cats.
+func (p Project) repositories(cats []string) []source.Repository {
+	category, ok := maturityCategories[p.Maturity]
+	if !ok {
+		return nil
+	}
+	codeOrgs := p.codeOrgs()
+	var res []source.Repository
+	for _, r := range p.Repositories {
+		repoCategory := r.category(category, codeOrgs)
+		if repoCategory == "" || !slices.Contains(cats, repoCategory) {
+			continue
+		}
+		res = append(res, source.Repository{URL: r.URL, Category: repoCategory})
+	}
+	return res
+}
+
+// codeOrgs returns the orgs ("<HOST>/<OWNER>") of the "code" repositories of p.
+func (p Project) codeOrgs() map[string]bool {
+	res := make(map[string]bool)
+	for _, r := range p.Repositories {
+		if !slices.Contains(r.CheckSets, "code") {
+			continue
+		}
+		if repo, err := forge.ParseRepoURL(r.URL); err == nil {
+			res[orgKey(*repo)] = true
+		}
+	}
+	return res
+}
+
+// category returns the category of r in a project of projectCategory, or the
+// empty string if r is not indexed.
+//
+// "code" repositories get projectCategory. For graduated projects, "code-lite"
+// repositories and the other listed repositories in the same org as a "code"
+// repository (most of them should be accidentally omitted from "code-lite") get
+// [categories.CNCFGraduatedSub]. The unlisted repositories of the orgs are
+// added by [Source.orgRepositories].
+func (r Repository) category(projectCategory string, codeOrgs map[string]bool) string {
+	if slices.Contains(r.CheckSets, "code") {
+		return projectCategory
+	}
+	if projectCategory != categories.CNCFGraduated {
+		return ""
+	}
+	if slices.Contains(r.CheckSets, "code-lite") {
+		return categories.CNCFGraduatedSub
+	}
+	if repo, err := forge.ParseRepoURL(r.URL); err == nil && codeOrgs[orgKey(*repo)] {
+		return categories.CNCFGraduatedSub
+	}
+	return ""
+}
+
+func repoKey(repo forge.Repo) string {
+	return strings.ToLower(path.Join(repo.Hostname(), repo.Owner, repo.Repo))
+}
+
+func orgKey(repo forge.Repo) string {
+	return strings.ToLower(repo.Hostname() + "/" + repo.Owner)
+}
@@ -0,0 +1,87 @@
+package cncf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
+func TestProjectRepositories(t *testing.T) {
+	repos := []Repository{
+		{URL: "https://github.com/kubernetes/kubernetes", CheckSets: []string{"code"}},
+		{URL: "https://github.com/kubernetes-sigs/kind", CheckSets: []string{"code-lite"}},
+		{URL: "https://github.com/Kubernetes/website", CheckSets: []string{"docs"}},
+		{URL: "https://github.com/example/unrelated", CheckSets: []string{"docs"}},
+	}
+	p := Project{Maturity: "graduated", Repositories: repos}
+	assert.DeepEqual(t, []source.Repository{
+		{URL: "https://github.com/kubernetes/kubernetes", Category: categories.CNCFGraduated},
+	}, p.repositories(categories.Default))
+	assert.DeepEqual(t, []source.Repository{
+		{URL: "https://github.com/kubernetes/kubernetes", Category: categories.CNCFGraduated},
+		{URL: "https://github.com/kubernetes-sigs/kind", Category: categories.CNCFGraduatedSub},
+		{URL: "https://github.com/Kubernetes/website", Category: categories.CNCFGraduatedSub},
+	}, p.repositories([]string{categories.CNCFGraduated, categories.CNCFGraduatedSub}))
+
+	// Only graduated projects have a "sub" category.
+	p = Project{Maturity: "sandbox", Repositories: repos}
+	assert.DeepEqual(t, []source.Repository{
+		{URL: "https://github.com/kubernetes/kubernetes", Category: categories.CNCFSandbox},
+	}, p.repositories(categories.All))
+	p = Project{Maturity: "archived", Repositories: repos}
+	assert.Equal(t, 0, len(p.repositories(categories.All)))
+}
+
+// fakeOwnerLister lists the repositories of static orgs.
+type fakeOwnerLister struct {
+	forge.Client
+	repo  forge.Repo
+	repos map[string][]string
+}
+
+func (f *fakeOwnerLister) OwnerRepos(context.Context, int, ...netutil.HTTPOpt) ([]forge.Repo, error) {
+	names, ok := f.repos[f.repo.Owner]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	var res []forge.Repo
+	for _, name := range names {
+		res = append(res, forge.Repo{Forge: f.repo.Forge, Host: f.repo.Host, Owner: f.repo.Owner, Repo: name})
+	}
+	return res, nil
+}
+
+func TestOrgRepositories(t *testing.T) {
+	orgs := map[string][]string{
+		"kubernetes":      {"kubernetes", "unlisted"},
+		"kubernetes-sigs": {"kind", "kustomize"},
+		"example":         {"unrelated-unlisted"},
+	}
+	s := &Source{newClient: func(repo forge.Repo) (forge.Client, error) {
+		return &fakeOwnerLister{repo: repo, repos: orgs}, nil
+	}}
+	projects := Projects{
+		{Maturity: "graduated", Repositories: []Repository{
+			{URL: "https://github.com/kubernetes/kubernetes", CheckSets: []string{"code"}},
+			{URL: "https://github.com/kubernetes-sigs/kind", CheckSets: []string{"code-lite"}},
+			{URL: "https://github.com/kubernetes/website", CheckSets: []string{"docs"}},
+			// The org cannot be listed.
+			{URL: "https://github.com/missing/foo", CheckSets: []string{"code"}},
+		}},
+		{Maturity: "sandbox", Repositories: []Repository{
+			{URL: "https://github.com/example/sandboxed", CheckSets: []string{"code"}},
+		}},
+	}
+	got := s.orgRepositories(context.TODO(), projects, source.Opts{})
+	assert.DeepEqual(t, []source.Repository{
+		{URL: "https://github.com/kubernetes/unlisted", Category: categories.CNCFGraduatedSub},
+		{URL: "https://github.com/kubernetes-sigs/kustomize", Category: categories.CNCFGraduatedSub},
+	}, got)
+}
@@ -0,0 +1,204 @@
+// Package custom reads user-defined trust sources from a configuration file.
+package custom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
+// ConfigFilename is the name of the configuration file under the gosocialcheck
+// directory of the user configuration directory (e.g., ~/.config/gosocialcheck).
+const ConfigFilename = "config.yaml"
+
+// DefaultConfigPath returns the path of the default configuration file.
+func DefaultConfigPath() (string, error) {
+	configHome, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configHome, "gosocialcheck", ConfigFilename), nil
+}
+
+// Config is the configuration file, e.g.:
+//
+//	sources:
+//	  - category: example.com::production
+//	    repositories:
+//	      - https://github.com/example/service-a
+//	      - https://github.com/example/service-b
+//
+//	source_refs:
+//	  cncf:
+//	    patches_per_minor: 2
+//	    minor_lines: 4
+//
+//	forges:
+//	  - host: github.example.com
+//	    type: github
+//	    api_url: https://github.example.com/api/v3
+type Config struct {
+	Sources []Source `yaml:"sources,omitempty"`
+	// SourceRefs overrides the ref selection of the repositories of the sources,
+	// keyed by [source.Source.Name] (e.g., "cncf", or "custom" for Sources).
+	SourceRefs map[string]source.Refs `yaml:"source_refs,omitempty"`
+	// Forges declares the self-hosted forges, such as GitHub Enterprise Server.
+	Forges []ForgeHost `yaml:"forges,omitempty"`
+}
+
+// ForgeHost is a self-hosted forge. See [forge.Host].
+type ForgeHost struct {
+	// Name is the host name in the repository URLs, e.g. "github.example.com".
+	Name       string `yaml:"host"`
+	forge.Host `yaml:",inline"`
+}
+
+// Source is a list of repositories trusted under a category.
+type Source struct {
+	// Category must not be one of [categories.All].
+	Category string `yaml:"category"`
+	// Repositories are the URLs of the repositories on the forges known to
+	// [forge.ParseRepoURL] or declared in [Config.Forges],
+	// e.g. "https://github.com/<OWNER>/<REPO>".
+	Repositories []string `yaml:"repositories"`
+	// Refs selects the refs of the repositories to index.
+	Refs source.Refs `yaml:"refs,omitempty"`
+	// DiscoverModules also indexes the nested modules of the repositories.
+	DiscoverModules bool `yaml:"discover_modules,omitempty"`
+}
+
+var _ source.Source = (*Config)(nil)
+
+// Name implements [source.Source].
+func (*Config) Name() string {
+	return "custom"
+}
+
+// Repositories implements [source.Source].
+func (cfg *Config) Repositories(_ context.Context, opts source.Opts) ([]source.Repository, error) {
+	var res []source.Repository
+	for _, src := range cfg.Sources {
+		if !slices.Contains(opts.Categories, src.Category) {
+			continue
+		}
+		for _, r := range src.Repositories {
+			res = append(res, source.Repository{
+				URL:             r,
+				Category:        src.Category,
+				Refs:            src.Refs,
+				DiscoverModules: src.DiscoverModules,
+			})
+		}
+	}
+	return res, nil
+}
+
+// Categories returns the categories of the sources, without duplicates.
+func (cfg *Config) Categories() []string {
+	var res []string
+	for _, src := range cfg.Sources {
+		if !slices.Contains(res, src.Category) {
+			res = append(res, src.Category)
+		}
+	}
+	return res
+}
+
+// RegisterForges registers [Config.Forges] with [forge.RegisterHost].
+func (cfg *Config) RegisterForges() {
+	for _, f := range cfg.Forges {
+		forge.RegisterHost(f.Name, f.Host)
+	}
+}
+
+// parseRepoURL parses the repository URL, taking [Config.Forges] into account
+// without registering them.
+func (cfg *Config) parseRepoURL(urlStr string) (*forge.Repo, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	for _, f := range cfg.Forges {
+		if strings.EqualFold(f.Name, host) {
+			return forge.ParseRepoURLOfKind(urlStr, f.Kind)
+		}
+	}
+	return forge.ParseRepoURL(urlStr)
+}
+
+// Validate checks the categories, the repository URLs, and the forges.
+func (cfg *Config) Validate() error {
+	var hosts []string
+	for i, f := range cfg.Forges {
+		h := strings.ToLower(f.Name)
+		switch {
+		case h == "":
+			return fmt.Errorf("forges[%d]: host is required", i)
+		case strings.ContainsAny(h, "/:@ \t\n"):
+			return fmt.Errorf("forges[%d]: invalid host %q (must be a host name, not a URL)", i, f.Name)
+		case slices.Contains(hosts, h):
+			return fmt.Errorf("forges[%d]: duplicate host %q", i, f.Name)
+		}
+		hosts = append(hosts, h)
+		if err := f.Host.Validate(); err != nil {
+			return fmt.Errorf("forges[%d]: %w", i, err)
+		}
+	}
+	for i, src := range cfg.Sources {
+		switch {
+		case src.Category == "":
+			return fmt.Errorf("sources[%d]: category is required", i)
+		case strings.ContainsAny(src.Category, ", \t\n"):
+			return fmt.Errorf("sources[%d]: category %q must not contain commas or spaces", i, src.Category)
+		case slices.Contains(categories.All, src.Category):
+			return fmt.Errorf("sources[%d]: category %q is reserved", i, src.Category)
+		}
+		if err := src.Refs.Validate(); err != nil {
+			return fmt.Errorf("sources[%d]: refs: %w", i, err)
+		}
+		for _, r := range src.Repositories {
+			if _, err := cfg.parseRepoURL(r); err != nil {
+				return fmt.Errorf("sources[%d]: %w", i, err)
+			}
+		}
+	}
+	for name, refs := range cfg.SourceRefs {
+		if err := refs.Validate(); err != nil {
+			return fmt.Errorf("source_refs[%q]: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Load reads and validates the configuration file.
+// When optional is true, a missing file yields an empty configuration.
+func Load(filename string, optional bool) (*Config, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+	var cfg Config
+	if err = yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", filename, err)
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %q: %w", filename, err)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,122 @@
+package custom
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := Load(filepath.Join(dir, "missing.yaml"), true)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(cfg.Sources))
+	_, err = Load(filepath.Join(dir, "missing.yaml"), false)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	f := filepath.Join(dir, ConfigFilename)
+	assert.NilError(t, os.WriteFile(f, []byte(`forges:
+  - host: github.example.com
+    type: github
+    api_url: https://github.example.com/api/v3
+sources:
+  - category: example.com::production
+    repositories:
+      - https://github.com/example/service-a
+  - category: example.com::audited
+    repositories:
+      - https://github.com/example/upstream
+  - category: example.com::production
+    repositories:
+      - https://github.com/example/service-b
+`), 0o644))
+	cfg, err = Load(f, false)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(cfg.Sources))
+	assert.DeepEqual(t, []ForgeHost{
+		{Name: "github.example.com", Host: forge.Host{Kind: forge.GitHub, APIURL: "https://github.example.com/api/v3"}},
+	}, cfg.Forges)
+	assert.DeepEqual(t, []string{"example.com::production", "example.com::audited"}, cfg.Categories())
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		src      Source
+		expected string
+	}{
+		{Source{Repositories: []string{"https://github.com/example/foo"}}, "category is required"},
+		{Source{Category: "a,b"}, "must not contain commas"},
+		{Source{Category: "cncf.io::graduated"}, "is reserved"},
+		{Source{Category: "example.com::foo", Repositories: []string{"https://example.com/foo"}}, "unknown forge host"},
+		{Source{Category: "example.com::foo", Repositories: []string{"https://gitlab.com/foo"}}, "invalid repo URL"},
+		{Source{Category: "example.com::foo", Refs: source.Refs{MaxTags: -1}}, "must not be negative"},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{Sources: []Source{tc.src}}
+		assert.ErrorContains(t, cfg.Validate(), tc.expected)
+	}
+
+	cfg := &Config{SourceRefs: map[string]source.Refs{"cncf": {TagPattern: "("}}}
+	assert.ErrorContains(t, cfg.Validate(), `source_refs["cncf"]: invalid tag_pattern`)
+}
+
+func TestValidateForges(t *testing.T) {
+	testCases := []struct {
+		forges   []ForgeHost
+		expected string
+	}{
+		{[]ForgeHost{{Host: forge.Host{Kind: forge.GitHub}}}, "host is required"},
+		{[]ForgeHost{{Name: "https://github.example.com", Host: forge.Host{Kind: forge.GitHub}}}, "invalid host"},
+		{[]ForgeHost{{Name: "github.example.com", Host: forge.Host{Kind: "bitbucket"}}}, "unknown forge type"},
+		{[]ForgeHost{{Name: "github.example.com", Host: forge.Host{Kind: forge.GitHub, APIURL: "github.example.com/api/v3"}}}, "invalid URL"},
+		{[]ForgeHost{
+			{Name: "github.example.com", Host: forge.Host{Kind: forge.GitHub}},
+			{Name: "GitHub.example.com", Host: forge.Host{Kind: forge.GitHub}},
+		}, "duplicate host"},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{Forges: tc.forges}
+		assert.ErrorContains(t, cfg.Validate(), tc.expected)
+	}
+
+	// The repositories on the forges in the config are valid without registering the forges.
+	cfg := &Config{
+		Sources: []Source{{Category: "example.com::foo", Repositories: []string{
+			"https://github.example.com/example/foo",
+			"https://gitlab.example.com/group/subgroup/foo",
+		}}},
+		Forges: []ForgeHost{
+			{Name: "github.example.com", Host: forge.Host{Kind: forge.GitHub, APIURL: "https://github.example.com/api/v3"}},
+			{Name: "gitlab.example.com", Host: forge.Host{Kind: forge.GitLab}},
+		},
+	}
+	assert.NilError(t, cfg.Validate())
+	_, err := forge.ParseRepoURL("https://github.example.com/example/foo")
+	assert.ErrorContains(t, err, "unknown forge host")
+
+	cfg.RegisterForges()
+	repo, err := forge.ParseRepoURL("https://gitlab.example.com/group/subgroup/foo")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, forge.Repo{Forge: forge.GitLab, Host: "gitlab.example.com", Owner: "group/subgroup", Repo: "foo"}, *repo)
+	h, ok := forge.LookupHost("github.example.com")
+	assert.Assert(t, ok)
+	assert.Equal(t, "https://github.example.com/api/v3", h.APIURL)
+}
+
+func TestRepositories(t *testing.T) {
+	cfg := &Config{Sources: []Source{
+		{Category: "example.com::production", Repositories: []string{"https://github.com/example/service-a"}},
+		{Category: "example.com::audited", Repositories: []string{"https://github.com/example/upstream"}, Refs: source.Refs{MaxTags: 3}},
+	}}
+	got, err := cfg.Repositories(context.TODO(), source.Opts{Categories: []string{"example.com::audited"}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []source.Repository{
+		{URL: "https://github.com/example/upstream", Category: "example.com::audited", Refs: source.Refs{MaxTags: 3}},
+	}, got)
+}
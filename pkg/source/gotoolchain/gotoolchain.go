@@ -0,0 +1,78 @@
+// Package gotoolchain implements the trust source of the Go project itself:
+// the Go distribution (github.com/golang/go) and the golang.org/x repositories.
+package gotoolchain
+
+import (
+	"context"
+	"slices"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
+// GoRepository is the Go distribution. Its modules ("std" and "cmd") live
+// under src, and vendor their dependencies.
+var GoRepository = source.Repository{
+	URL:      "https://github.com/golang/go",
+	Category: categories.GoToolchain,
+	Refs: source.Refs{
+		// Skip the release candidates and the ancient "weekly.*" and "release.*" tags.
+		TagPattern: `^go1\.\d+(\.\d+)?$`,
+	},
+	Dirs:   []string{"src", "src/cmd"},
+	Vendor: true,
+}
+
+// XRepositories are the golang.org/x repositories (mirrored on GitHub),
+// with the directories of their nested modules that are worth indexing.
+var XRepositories = map[string][]string{
+	"arch":      nil,
+	"build":     nil,
+	"crypto":    nil,
+	"exp":       nil,
+	"image":     nil,
+	"mod":       nil,
+	"net":       nil,
+	"oauth2":    nil,
+	"pkgsite":   nil,
+	"sync":      nil,
+	"sys":       nil,
+	"telemetry": nil,
+	"term":      nil,
+	"text":      nil,
+	"time":      nil,
+	"tools":     {"", "gopls"},
+	"vuln":      nil,
+	"website":   nil,
+}
+
+func init() {
+	source.Register(&Source{})
+}
+
+// Source is the [source.Source] of the Go project.
+type Source struct{}
+
+func (*Source) Name() string {
+	return "gotoolchain"
+}
+
+func (*Source) Repositories(_ context.Context, opts source.Opts) ([]source.Repository, error) {
+	if !slices.Contains(opts.Categories, categories.GoToolchain) {
+		return nil, nil
+	}
+	res := []source.Repository{GoRepository}
+	names := make([]string, 0, len(XRepositories))
+	for name := range XRepositories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		res = append(res, source.Repository{
+			URL:      "https://github.com/golang/" + name,
+			Category: categories.GoToolchain,
+			Dirs:     XRepositories[name],
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,44 @@
+package gotoolchain
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/categories"
+	"github.com/AkihiroSuda/gosocialcheck/pkg/source"
+)
+
+func TestRepositories(t *testing.T) {
+	s := &Source{}
+	got, err := s.Repositories(context.TODO(), source.Opts{Categories: []string{categories.CNCFGraduated}})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(got))
+
+	got, err = s.Repositories(context.TODO(), source.Opts{Categories: []string{categories.GoToolchain}})
+	assert.NilError(t, err)
+	assert.Equal(t, 1+len(XRepositories), len(got))
+	assert.DeepEqual(t, GoRepository, got[0])
+	for _, r := range got {
+		assert.Equal(t, categories.GoToolchain, r.Category)
+		if r.URL == "https://github.com/golang/tools" {
+			assert.DeepEqual(t, []string{"", "gopls"}, r.ModuleDirs())
+		}
+	}
+}
+
+func TestTagPattern(t *testing.T) {
+	re := regexp.MustCompile(GoRepository.Refs.TagPattern)
+	for tag, expected := range map[string]bool{
+		"go1.24":                true,
+		"go1.24.3":              true,
+		"go1.25rc1":             false,
+		"weekly.2012-03-27":     false,
+		"release.r60":           false,
+		"go1.24.3-boringcrypto": false,
+	} {
+		assert.Equal(t, expected, re.MatchString(tag), tag)
+	}
+}
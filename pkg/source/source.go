@@ -0,0 +1,149 @@
+// Package source defines the trust sources: the lists of trusted repositories
+// indexed into the cache by [ModeLocal] updates.
+//
+// Built-in sources register themselves with [Register] in their init function,
+// and are linked in by importing [github.com/AkihiroSuda/gosocialcheck/pkg/source/builtin].
+//
+// [ModeLocal]: https://pkg.go.dev/github.com/AkihiroSuda/gosocialcheck/pkg/cache#ModeLocal
+package source
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/AkihiroSuda/gosocialcheck/pkg/netutil"
+)
+
+// DefaultMaxTags is the default number of the latest release tags indexed per
+// repository.
+const DefaultMaxTags = 10
+
+// Refs selects the refs of a repository to index.
+type Refs struct {
+	// MaxTags is the maximum number of the latest release tags to index.
+	// Zero means [DefaultMaxTags].
+	MaxTags int `yaml:"max_tags,omitempty" json:"max_tags,omitempty"`
+	// TagPattern is a regular expression that the names of the indexed tags
+	// have to match, e.g. `^go1\.\d+(\.\d+)?$`. Empty means any release tag.
+	TagPattern string `yaml:"tag_pattern,omitempty" json:"tag_pattern,omitempty"`
+	// PatchesPerMinor, when non-zero, selects the latest N patch releases of
+	// each minor release line (e.g. v1.33.x), instead of just the latest tags.
+	// MaxTags still caps the total.
+	PatchesPerMinor int `yaml:"patches_per_minor,omitempty" json:"patches_per_minor,omitempty"`
+	// MinorLines limits PatchesPerMinor to the latest N minor release lines
+	// (e.g. the supported ones). Zero means every minor release line.
+	MinorLines int `yaml:"minor_lines,omitempty" json:"minor_lines,omitempty"`
+	// DefaultBranch also indexes the tip of the default branch, so that
+	// adoption is recognized before the next release.
+	DefaultBranch bool `yaml:"default_branch,omitempty" json:"default_branch,omitempty"`
+	// BranchPattern is a regular expression of the names of the other branches
+	// whose tips are indexed, e.g. `^release-1\.\d+$`. Empty means none.
+	BranchPattern string `yaml:"branch_pattern,omitempty" json:"branch_pattern,omitempty"`
+}
+
+// Override returns r with the non-zero fields of o.
+func (r Refs) Override(o Refs) Refs {
+	if o.MaxTags != 0 {
+		r.MaxTags = o.MaxTags
+	}
+	if o.TagPattern != "" {
+		r.TagPattern = o.TagPattern
+	}
+	if o.PatchesPerMinor != 0 {
+		r.PatchesPerMinor = o.PatchesPerMinor
+	}
+	if o.MinorLines != 0 {
+		r.MinorLines = o.MinorLines
+	}
+	if o.DefaultBranch {
+		r.DefaultBranch = true
+	}
+	if o.BranchPattern != "" {
+		r.BranchPattern = o.BranchPattern
+	}
+	return r
+}
+
+// Validate checks that the numbers are not negative and that the patterns
+// compile.
+func (r Refs) Validate() error {
+	if r.MaxTags < 0 || r.PatchesPerMinor < 0 || r.MinorLines < 0 {
+		return fmt.Errorf("max_tags, patches_per_minor, and minor_lines must not be negative")
+	}
+	if _, err := regexp.Compile(r.TagPattern); err != nil {
+		return fmt.Errorf("invalid tag_pattern: %w", err)
+	}
+	if _, err := regexp.Compile(r.BranchPattern); err != nil {
+		return fmt.Errorf("invalid branch_pattern: %w", err)
+	}
+	return nil
+}
+
+// Repository is a trusted repository.
+type Repository struct {
+	// URL is the URL of the repository, e.g. "https://github.com/<OWNER>/<REPO>".
+	// See [github.com/AkihiroSuda/gosocialcheck/pkg/netutil/forge.ParseRepoURL] for the supported forges.
+	URL      string `json:"url"`
+	Category string `json:"category"`
+	Refs     Refs   `json:"refs,omitzero"`
+	// Dirs are the slash-separated directories of the go.mod files to index,
+	// relative to the repository root. Empty means the root directory.
+	Dirs []string `json:"dirs,omitempty"`
+	// Vendor also indexes vendor/modules.txt next to each go.mod.
+	Vendor bool `json:"vendor,omitempty"`
+	// DiscoverModules indexes every (nested) module found in the tree of the
+	// repository, with its vendor/modules.txt, instead of Dirs.
+	DiscoverModules bool `json:"discover_modules,omitempty"`
+}
+
+// ModuleDirs returns r.Dirs, or the root directory if r.Dirs is empty.
+func (r Repository) ModuleDirs() []string {
+	if len(r.Dirs) == 0 {
+		return []string{""}
+	}
+	return r.Dirs
+}
+
+// Opts is passed to [Source.Repositories].
+type Opts struct {
+	// Categories are the trusted categories. Sources may skip the repositories
+	// of the other categories, which are ignored anyway.
+	Categories []string
+	// HTTPOpts are the options for [netutil.Get].
+	HTTPOpts []netutil.HTTPOpt
+}
+
+// Source is a trust source.
+type Source interface {
+	// Name returns a unique short name, e.g. "cncf".
+	Name() string
+	// Repositories lists the trusted repositories with their categories.
+	Repositories(ctx context.Context, opts Opts) ([]Repository, error)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Source
+)
+
+// Register registers a built-in source. It panics if a source with the same
+// name is already registered.
+func Register(s Source) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, r := range registry {
+		if r.Name() == s.Name() {
+			panic(fmt.Errorf("source %q is already registered", s.Name()))
+		}
+	}
+	registry = append(registry, s)
+}
+
+// Registered returns the registered sources, in the order of registration.
+func Registered() []Source {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]Source(nil), registry...)
+}
@@ -0,0 +1,43 @@
+package source
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type fakeSource struct {
+	name string
+}
+
+func (s *fakeSource) Name() string {
+	return s.name
+}
+
+func (s *fakeSource) Repositories(context.Context, Opts) ([]Repository, error) {
+	return nil, nil
+}
+
+func TestRegister(t *testing.T) {
+	n := len(Registered())
+	Register(&fakeSource{name: "test-register"})
+	got := Registered()
+	assert.Equal(t, n+1, len(got))
+	assert.Equal(t, "test-register", got[n].Name())
+	assert.Assert(t, func() (panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		Register(&fakeSource{name: "test-register"})
+		return false
+	}())
+}
+
+func TestRefs(t *testing.T) {
+	r := Refs{MaxTags: 5, TagPattern: "^v"}
+	assert.DeepEqual(t, Refs{MaxTags: 5, TagPattern: "^v", PatchesPerMinor: 2}, r.Override(Refs{PatchesPerMinor: 2}))
+	assert.DeepEqual(t, Refs{MaxTags: 30, TagPattern: "^v"}, r.Override(Refs{MaxTags: 30}))
+
+	assert.NilError(t, r.Validate())
+	assert.ErrorContains(t, Refs{MinorLines: -1}.Validate(), "must not be negative")
+	assert.ErrorContains(t, Refs{TagPattern: "("}.Validate(), "invalid tag_pattern")
+}